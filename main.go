@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -13,10 +14,12 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 
+	"github.com/jimed-rand/cosmic-deb/pkg/audit"
 	"github.com/jimed-rand/cosmic-deb/pkg/build"
 	"github.com/jimed-rand/cosmic-deb/pkg/debian"
 	"github.com/jimed-rand/cosmic-deb/pkg/distro"
 	"github.com/jimed-rand/cosmic-deb/pkg/repos"
+	"github.com/jimed-rand/cosmic-deb/pkg/sbom"
 	"github.com/jimed-rand/cosmic-deb/pkg/thermal"
 	"github.com/jimed-rand/cosmic-deb/pkg/tui"
 )
@@ -29,13 +32,52 @@ var (
 	flagOutDir      = flag.String("outdir", "cosmic-packages", "Output directory for .deb files")
 	flagJobs        = flag.Int("jobs", 0, "Number of parallel compilation jobs (0 = nproc)")
 	flagSkipDeps    = flag.Bool("skip-deps", false, "Skip build dependency installation")
-	flagOnly        = flag.String("only", "", "Build a single named component")
+	flagOnly        = flag.String("only", "", "Build only the named components (comma-separated) and their prerequisites")
 	flagTUI         = flag.Bool("tui", false, "Launch the TUI configuration wizard")
 	flagUpdateRepos = flag.Bool("update-repos", false, "Fetch latest epoch tags and overwrite repos config")
 	flagGenConfig   = flag.Bool("gen-config", false, "Export built-in config to repos.json")
 	flagDevFinder   = flag.Bool("dev-finder", false, "Regenerate pkg/repos/finder.go from active schema")
 	flagVerbose     = flag.Bool("verbose", false, "Enable verbose build output")
 	flagNoThermal   = flag.Bool("no-thermal", false, "Disable thermal build limiter for low-end CPUs")
+	flagNoSBOM      = flag.Bool("no-sbom", false, "Disable CycloneDX SBOM generation from Cargo.lock")
+	flagAdvisoryDB  = flag.String("advisory-db", "", "Path to a local RustSec advisory-db snapshot for auditing Cargo.lock")
+	flagStaticDeps  = flag.Bool("static-deps", false, "Use the built-in per-component dependency table instead of upstream debian/control files")
+	flagCapFile     = flag.String("capabilities", "", "Path to a JSON capability matrix extending the built-in package alternatives")
+	flagArch        = flag.String("arch", "", "Debian architecture to label packages with (default: dpkg --print-architecture)")
+	flagExplainDeps = flag.Bool("explain-deps", false, "List the build dependencies of the selected components with the components requiring each, then exit")
+	flagPurgeDeps   = flag.Bool("purge-deps", false, "Purge the build dependencies installed by cosmic-deb once the build finishes")
+	flagPurgeOnly   = flag.Bool("purge-deps-only", false, "Purge the build dependencies recorded in the workdir manifest, then exit")
+	flagAptSimulate = flag.Bool("apt-simulate", false, "Simulate APT operations (apt-get -s) and exit after the dependency stage")
+	flagAptLockWait = flag.Duration("apt-lock-timeout", 10*time.Minute, "Maximum time to wait for the dpkg lock held by another process")
+	flagAptMaxAge   = flag.Duration("apt-max-age", 24*time.Hour, "Refresh APT package indices older than this before installing (0 disables)")
+	flagPrivHelper  = flag.String("privilege-helper", "auto", "Command used to gain root for package management (auto|sudo|doas|run0|pkexec|custom command)")
+	flagRelCodename = flag.Bool("release-codename", false, "Suffix package versions with the distribution's own codename (e.g. wilma) instead of its base codename")
+	flagNoPreflight = flag.Bool("no-preflight", false, "Skip the pkg-config, header and tool pre-flight check before compiling each component")
+	flagAutoInstall = flag.Bool("auto-install-missing", false, "Install the package named by a compile failure diagnosis and retry the component once")
+	flagCargoProf   = flag.String("cargo-profile", "auto", "Cargo release profile: preset (auto|balanced|lowmem|release|upstream) and/or lto=,codegen-units=,opt-level=,debuginfo=,panic=,target-cpu= overrides")
+	flagLinker      = flag.String("linker", "auto", "Linker for Rust and C code: auto (mold, then lld, then system default), mold, lld or system")
+	flagSharedTgt   = flag.String("shared-target", "off", "Share a Cargo target directory between components: off, single (one directory) or bucket (one per libcosmic revision)")
+	flagKeepTarget  = flag.Bool("keep-target", false, "Keep the shared Cargo target directory after the run for reuse by later runs")
+	flagCleanTarget = flag.Bool("clean-target", false, "Report and remove the shared Cargo target directory in the workdir, then exit")
+	flagPersistTC   = flag.Bool("persistent-toolchain", false, "Keep the isolated Rust toolchain, crate registry and cargo-installed tools between runs")
+	flagToolchainTo = flag.String("toolchain-dir", "", "Location of the persistent Rust toolchain (default: ~/.cache/cosmic-deb/rust)")
+	flagTCUpdate    = flag.Bool("toolchain-update", false, "Run rustup update on the persistent Rust toolchain before building")
+	flagToolchainGC = flag.Bool("toolchain-gc", false, "Remove unused toolchains and download caches from the persistent Rust toolchain, then exit")
+	flagRustTC      = flag.String("rust-toolchain", "stable", "Rust toolchain for components without a repos.json or upstream rust-toolchain.toml pin (stable, 1.85.0, nightly-2025-06-01, ...)")
+	flagRustupVer   = flag.String("rustup-version", build.DefaultRustupVersion, "rustup-init release downloaded when rustup is not installed")
+	flagRustupSum   = flag.String("rustup-sha256", "", "Expected SHA-256 of rustup-init (default: the checksum published alongside it)")
+	flagRustupInit  = flag.String("rustup-init", "", "Local rustup-init binary to install instead of downloading it")
+	flagRustArchive = flag.String("rust-archive", "", "Offline standalone Rust archive (rust-<version>-<triple>.tar.xz) to install as the default toolchain")
+	flagJustVer     = flag.String("just-version", build.DefaultJustVersion, "Pinned 'just' version used for prebuilt archives and cargo install --locked")
+	flagJustArchive = flag.String("just-archive", "", "Local prebuilt 'just' release archive installed when the distribution does not package just")
+	flagJustSum     = flag.String("just-sha256", "", "Expected SHA-256 of -just-archive (default: <archive>.sha256)")
+	flagSccache     = flag.Bool("sccache", false, "Cache Rust and C compilation with sccache (RUSTC_WRAPPER and CC/CXX wrapper)")
+	flagSccacheDir  = flag.String("sccache-dir", "", "sccache cache directory, kept across runs (default: <workdir>/.sccache)")
+	flagSccacheSize = flag.String("sccache-size", "10G", "Maximum size of the sccache cache directory")
+	flagSccacheVer  = flag.String("sccache-version", build.DefaultSccacheVersion, "Pinned sccache version built with cargo install --locked when sccache is not installed")
+	flagPatches     = flag.String("patches", "patches", "Directory of per-component patch queues (<dir>/<component>/series or *.patch)")
+	flagCheckPatch  = flag.Bool("check-patches", false, "Check that the patch queues of the selected components still apply, then exit without building")
+	flagAuditFailOn = flag.String("audit-fail-on", "", "Fail a component whose audit finds an advisory at or above this severity (low|medium|high|critical)")
 )
 
 func log(format string, args ...any) {
@@ -50,14 +92,6 @@ func logVerbose(verbose bool, format string, args ...any) {
 	}
 }
 
-func execShell(name string, args ...string) error {
-	cmd := exec.Command(name, args...)
-	cmd.Env = os.Environ()
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
-}
-
 func nproc() int {
 	cmd := exec.Command("nproc")
 	out, err := cmd.Output()
@@ -77,17 +111,75 @@ func main() {
 	verbose := *flagVerbose
 
 	log("cosmic-deb starting up")
-	logVerbose(verbose, "Parsed flags: repos=%s tag=%s use-branch=%v workdir=%s outdir=%s jobs=%d skip-deps=%v only=%s tui=%v verbose=%v no-thermal=%v",
-		*flagRepos, *flagTag, *flagUseBranch, *flagWorkDir, *flagOutDir, *flagJobs, *flagSkipDeps, *flagOnly, *flagTUI, verbose, *flagNoThermal)
+	logVerbose(verbose, "Parsed flags: repos=%s tag=%s use-branch=%v workdir=%s outdir=%s jobs=%d skip-deps=%v only=%s tui=%v verbose=%v no-thermal=%v no-sbom=%v",
+		*flagRepos, *flagTag, *flagUseBranch, *flagWorkDir, *flagOutDir, *flagJobs, *flagSkipDeps, *flagOnly, *flagTUI, verbose, *flagNoThermal, *flagNoSBOM)
+
+	build.ConfigureApt(build.AptConfig{
+		Simulate:    *flagAptSimulate,
+		LockTimeout: *flagAptLockWait,
+		MaxIndexAge: *flagAptMaxAge,
+	})
+
+	build.SetPrivilegeInteractive(build.StdinIsTerminal())
+	if err := build.ConfigureEscalator(*flagPrivHelper); err != nil && *flagPrivHelper != "auto" {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
 
 	thermalProfile := thermal.DetectProfile()
+	hostEnv := thermalProfile.Environment
+	log("Execution environment: %s", hostEnv)
 	if !*flagNoThermal {
 		thermal.SummarizeThermalProfile(thermalProfile, func(f string, a ...any) { log(f, a...) })
 	}
 
-	cfg, cfgPath := repos.Load(*flagRepos)
-	if cfg == nil {
-		fmt.Fprintf(os.Stderr, "ERROR: Failed to load repos config from '%s'\n", *flagRepos)
+	if err := build.ConfigureSharedTarget(*flagSharedTgt); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: -shared-target: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *flagPersistTC || *flagToolchainGC {
+		dir, err := build.ConfigurePersistentToolchain(*flagToolchainTo, *flagTCUpdate)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: -toolchain-dir: %v\n", err)
+			os.Exit(1)
+		}
+		log("Persistent Rust toolchain: %s", dir)
+	} else if *flagTCUpdate || *flagToolchainTo != "" {
+		log("WARNING: -toolchain-dir and -toolchain-update only apply with -persistent-toolchain")
+	}
+
+	if err := build.ConfigureRustSetup(build.RustSetup{
+		Toolchain:     *flagRustTC,
+		RustupVersion: *flagRustupVer,
+		RustupSHA256:  *flagRustupSum,
+		RustupInit:    *flagRustupInit,
+		Archive:       *flagRustArchive,
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: Rust toolchain: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := build.ConfigureJust(build.JustSetup{Version: *flagJustVer, Archive: *flagJustArchive, SHA256: *flagJustSum}); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: -just-archive: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := build.CheckLinkerStrategy(*flagLinker); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: -linker: %v\n", err)
+		os.Exit(1)
+	}
+
+	cargoProfile, err := build.ConfigureCargoProfile(*flagCargoProf, thermalProfile.IsLowEnd || thermalProfile.LowMemory)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: -cargo-profile: %v\n", err)
+		os.Exit(1)
+	}
+	log("Cargo release profile: %s", build.DescribeCargoProfile(cargoProfile))
+
+	cfg, cfgPath, err := repos.Load(*flagRepos)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: Failed to load repos config from '%s': %v\n", *flagRepos, err)
 		os.Exit(1)
 	}
 	log("Loaded repos config: %s (%d repositories)", cfgPath, len(cfg.Repos))
@@ -128,18 +220,56 @@ func main() {
 		return
 	}
 
+	if *flagCapFile != "" {
+		if err := distro.LoadCapabilityFile(*flagCapFile); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: Cannot load capability matrix: %v\n", err)
+			os.Exit(1)
+		}
+		logVerbose(verbose, "Loaded capability matrix overrides from %s", *flagCapFile)
+	}
+
 	di := distro.Detect()
-	log("Detected distribution: %s %s", di.ID, di.Codename)
+	if di.Derived() {
+		log("Detected distribution: %s %s (based on %s %s)", di.ReleaseID, di.ReleaseCodename, di.ID, di.Codename)
+	} else {
+		log("Detected distribution: %s %s", di.ID, di.Codename)
+	}
+	nameCodename := di.Codename
+	if *flagRelCodename {
+		nameCodename = di.ReleaseCodename
+	}
 
 	if ok, reason := distro.CheckSupported(di.ID, di.Codename); !ok {
 		fmt.Fprintf(os.Stderr, "ERROR: %s\n", reason)
 		os.Exit(1)
 	}
 
+	targetArch := *flagArch
+	if targetArch == "" {
+		targetArch = debian.HostArch()
+	}
+	hostArch := debian.HostArch()
+	var crossTarget *build.CrossTarget
+	if targetArch != hostArch {
+		t, err := build.CrossTargetFor(targetArch)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(1)
+		}
+		crossTarget = &t
+		log("Target architecture: %s (cross-compiling from %s via %s)", targetArch, hostArch, t.RustTriple)
+	} else {
+		log("Target architecture: %s", targetArch)
+	}
+
 	jobs := *flagJobs
 	if jobs <= 0 {
 		jobs = nproc()
 		logVerbose(verbose, "Detected %d logical CPUs via nproc", jobs)
+		if limit := thermalProfile.Environment.CPULimit; limit > 0 && jobs > limit {
+			jobs = limit
+			logVerbose(verbose, "Capped jobs to the cgroup CPU quota of %d", limit)
+		}
 	}
 
 	if !*flagNoThermal && thermalProfile.IsLowEnd {
@@ -192,7 +322,7 @@ func main() {
 		if v, ok := choices["only"]; ok {
 			onlyComp = v
 		}
-	} else if globalTag == "" && !*flagUseBranch {
+	} else if globalTag == "" && !*flagUseBranch && !*flagPurgeOnly && !*flagCleanTarget && !*flagExplainDeps && !*flagCheckPatch {
 		logVerbose(verbose, "No tag or branch flag specified; entering interactive source selection")
 		globalTag = interactiveSelectTag(cfg, verbose)
 	}
@@ -224,29 +354,136 @@ func main() {
 	}
 	logVerbose(verbose, "Working directories created: %s, %s", workDir, outDir)
 
+	if *flagCleanTarget {
+		build.ReportSharedTarget(workDir, func(f string, a ...any) { log(f, a...) })
+		if err := build.CleanSharedTarget(workDir, func(f string, a ...any) { log(f, a...) }); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *flagToolchainGC {
+		keep := []string{*flagRustTC}
+		for _, r := range cfg.Repos {
+			if r.Build != nil && r.Build.Toolchain != "" {
+				keep = append(keep, r.Build.Toolchain)
+			}
+		}
+		if err := build.CollectToolchainGarbage(keep, func(f string, a ...any) { log(f, a...) }); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: Toolchain garbage collection failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if (!skipDeps || *flagPurgeOnly) && !*flagAptSimulate && !*flagCheckPatch && !*flagExplainDeps {
+		if err := build.PreflightPrivileges(func(f string, a ...any) { log(f, a...) }); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *flagPurgeOnly {
+		if err := build.PurgeInstalledDeps(workDir, func(f string, a ...any) { log(f, a...) }); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: Purging build dependencies failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	targetRepos, err := repos.ResolveTargets(cfg, repos.ParseComponentList(onlyComp))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	var targetNames []string
+	for _, r := range targetRepos {
+		targetNames = append(targetNames, r.Name)
+	}
+	log("Build order (%d components): %s", len(targetRepos), strings.Join(targetNames, ", "))
+
+	if *flagCheckPatch {
+		if err := checkPatches(targetRepos, globalTag, workDir, func(f string, a ...any) { log(f, a...) }); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *flagExplainDeps {
+		perComponent := distro.PerComponentBuildDeps(di.ID, di.Codename)
+		if !*flagStaticDeps {
+			controlDeps(perComponent, targetRepos, globalTag, *flagUseBranch, targetArch, func(f string, a ...any) { log(f, a...) })
+		}
+		addExtraDeps(perComponent, targetRepos)
+		explainDeps(distro.ExplainBuildDeps(di.ID, di.Codename, perComponent, targetNames))
+		return
+	}
+
 	if !skipDeps {
 		log("Checking build dependencies")
 		if !distro.IsAptBased() {
 			fmt.Fprintf(os.Stderr, "ERROR: APT or dpkg not found; this program requires a Debian-based system\n")
 			os.Exit(1)
 		}
-		allDeps := distro.CollectAllBuildDeps(di.ID, di.Codename)
+		if err := build.RefreshAptIndices(false, func(f string, a ...any) { log(f, a...) }); err != nil {
+			log("WARNING: %v", err)
+		}
+		perComponent := distro.PerComponentBuildDeps(di.ID, di.Codename)
+		if !*flagStaticDeps {
+			controlDeps(perComponent, targetRepos, globalTag, *flagUseBranch, targetArch, func(f string, a ...any) { log(f, a...) })
+		}
+		addExtraDeps(perComponent, targetRepos)
+		allDeps := distro.ScopedBuildDeps(di.ID, di.Codename, perComponent, targetNames)
+		if pkg := build.LinkerPackage(*flagLinker); pkg != "" {
+			allDeps = append(allDeps, pkg)
+		}
+		if *flagSccache && distro.AptMetadataAvailable() {
+			if pkg, ok, _ := distro.ResolvePackage("sccache"); ok {
+				allDeps = append(allDeps, pkg)
+			}
+		}
+		if distro.AptMetadataAvailable() {
+			resolved, unavailable := distro.ResolveAlternatives(allDeps)
+			if len(unavailable) > 0 {
+				log("WARNING: No installable candidate in APT for: %s", strings.Join(unavailable, ", "))
+			}
+			allDeps = resolved
+		} else {
+			log("WARNING: APT package lists are missing; using the static capability table for %s %s", di.ID, di.Codename)
+		}
+		if crossTarget != nil {
+			if err := build.EnableForeignArch(targetArch, func(f string, a ...any) { log(f, a...) }); err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: Cannot enable foreign architecture %s: %v\n", targetArch, err)
+				os.Exit(1)
+			}
+			allDeps = distro.CrossBuildDeps(allDeps, targetArch)
+		}
 		logVerbose(verbose, "Total build dependency list: %d packages", len(allDeps))
 		missing := build.CheckPackagesInstalled(allDeps)
 		if len(missing) > 0 {
 			log("Installing %d missing packages: %s", len(missing), strings.Join(missing, ", "))
-			if err := build.InstallPackages(missing, func(f string, a ...any) { log(f, a...) }); err != nil {
+			if err := build.InstallPackages(workDir, missing, func(f string, a ...any) { log(f, a...) }); err != nil {
 				fmt.Fprintf(os.Stderr, "ERROR: Package installation failed: %v\n", err)
 				os.Exit(1)
 			}
 		} else {
 			log("All build dependencies are satisfied")
 		}
-		if err := build.EnsureRustToolchain(workDir, func(f string, a ...any) { log(f, a...) }); err != nil {
+		if build.AptSimulating() {
+			log("APT simulation complete; exiting before the build (-apt-simulate)")
+			return
+		}
+		var rustTargets []string
+		if crossTarget != nil {
+			rustTargets = append(rustTargets, crossTarget.RustTriple)
+		}
+		if err := build.EnsureRustToolchain(workDir, rustTargets, func(f string, a ...any) { log(f, a...) }); err != nil {
 			fmt.Fprintf(os.Stderr, "ERROR: Rust toolchain setup failed: %v\n", err)
 			os.Exit(1)
 		}
-		if err := build.EnsureJust(workDir, func(f string, a ...any) { log(f, a...) }); err != nil {
+		if err := build.EnsureJust(workDir, di.ID, di.Codename, func(f string, a ...any) { log(f, a...) }); err != nil {
 			fmt.Fprintf(os.Stderr, "ERROR: 'just' installation failed: %v\n", err)
 			os.Exit(1)
 		}
@@ -255,43 +492,79 @@ func main() {
 		build.ApplyIsolatedRustEnv(workDir)
 	}
 
+	if *flagSccache {
+		if err := build.EnableSccache(workDir, *flagSccacheDir, *flagSccacheSize, *flagSccacheVer, func(f string, a ...any) { log(f, a...) }); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(1)
+		}
+		defer build.StopSccache()
+	}
+
+	if crossTarget != nil {
+		if err := build.CheckCrossToolchain(*crossTarget); err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+			os.Exit(1)
+		}
+		build.ApplyCrossEnv(*crossTarget)
+		logVerbose(verbose, "Cross-compilation environment: %s", strings.Join(crossTarget.Env(), " "))
+	}
+
+	linker, err := build.ConfigureLinker(*flagLinker, workDir, func(f string, a ...any) { logVerbose(verbose, f, a...) })
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	log("Linker: %s (-linker %s)", linker, *flagLinker)
+
 	defer func() {
 		if !skipDeps {
 			build.PurgeIsolatedRustEnv(workDir, func(f string, a ...any) { log(f, a...) })
 		}
 	}()
 
-	targetRepos := cfg.Repos
-	if onlyComp != "" {
-		logVerbose(verbose, "Filtering to single component: %s", onlyComp)
-		var filtered []repos.Entry
-		for _, r := range cfg.Repos {
-			if r.Name == onlyComp {
-				filtered = append(filtered, r)
+	if build.SharingTarget() {
+		log("Sharing Cargo target directory across components: %s (%s)", build.SharedTargetRoot(workDir), *flagSharedTgt)
+		defer func() {
+			build.ReportSharedTarget(workDir, func(f string, a ...any) { log(f, a...) })
+			if *flagKeepTarget {
+				log("Keeping shared Cargo target directory for later runs (-keep-target)")
+				return
 			}
-		}
-		if len(filtered) == 0 {
-			fmt.Fprintf(os.Stderr, "ERROR: Component '%s' not found in repos config\n", onlyComp)
-			os.Exit(1)
-		}
-		targetRepos = filtered
+			if err := build.CleanSharedTarget(workDir, func(f string, a ...any) { log(f, a...) }); err != nil {
+				log("WARNING: %v", err)
+			}
+		}()
 	}
 
-	sort.Slice(targetRepos, func(i, j int) bool {
-		return targetRepos[i].Name < targetRepos[j].Name
-	})
-	log("Build order (%d components): %s", len(targetRepos), func() string {
-		var names []string
-		for _, r := range targetRepos {
-			names = append(names, r.Name)
+	var advisoryDB *audit.Database
+	auditThreshold := audit.SeverityUnknown
+	if *flagAdvisoryDB != "" {
+		db, err := audit.LoadDatabase(*flagAdvisoryDB)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "ERROR: Cannot load advisory database: %v\n", err)
+			os.Exit(1)
 		}
-		return strings.Join(names, ", ")
-	}())
+		advisoryDB = db
+		log("Loaded RustSec advisory database: %s (%d advisories)", db.Path, db.Advisories)
+		if *flagAuditFailOn != "" {
+			sev, err := audit.ParseSeverity(*flagAuditFailOn)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+				os.Exit(1)
+			}
+			auditThreshold = sev
+			log("Components with advisories of severity %s or above will fail the build", sev)
+		}
+	} else if *flagAuditFailOn != "" {
+		fmt.Fprintf(os.Stderr, "ERROR: -audit-fail-on requires -advisory-db\n")
+		os.Exit(1)
+	}
 
 	maintainerName, maintainerEmail := repos.MaintainerFromUpstream()
 	log("Package maintainer: %s <%s>", maintainerName, maintainerEmail)
 
 	var builtPkgs []string
+	var builtDebs []string
 	total := len(targetRepos)
 
 	var monitorCh chan tui.ProgressMsg
@@ -300,6 +573,7 @@ func main() {
 
 	useTUIMonitor := *flagTUI
 	if useTUIMonitor {
+		build.SetPrivilegeInteractive(false)
 		monitorCh = make(chan tui.ProgressMsg, 32)
 		logCh = make(chan tui.LogMsg, 256)
 		doneCh = make(chan tui.DoneMsg, 1)
@@ -332,15 +606,96 @@ func main() {
 		stageDir := filepath.Join(workDir, repo.Name+"-stage")
 		logVerbose(verbose, "Source directory: %s", repoDir)
 
-		debianSubdir := filepath.Join(repoDir, "debian")
-		if info, err := os.Stat(debianSubdir); err == nil && info.IsDir() {
-			logVerbose(verbose, "Found debian/ subdirectory in %s; using dpkg-buildpackage path", repo.Name)
-			if err := build.BuildWithDebianDir(repoDir, outDir, workDir, logFn); err != nil {
-				log("WARNING: debian/ build failed for %s: %v; attempting manual path", repo.Name, err)
+		info := build.NewBuildInfo(repo.Name, "", effectiveTag)
+		patches, err := applyPatchQueue(repoDir, repo.Name, logFn)
+		if err != nil {
+			log("ERROR: Patch queue failed for %s: %v", repo.Name, err)
+			buildErr = err
+			build.CleanSource(repoDir, stageDir, logFn)
+			continue
+		}
+		info.Patches = patches
+		info.CargoProfile = build.DescribeCargoProfile(build.ComponentCargoProfile(repo.Build, repoDir))
+		info.Linker = build.ActiveLinker()
+		rustc, err := build.EnsureComponentToolchain(repo.Build, repoDir, workDir, logFn)
+		if err != nil {
+			log("ERROR: Rust toolchain setup failed for %s: %v", repo.Name, err)
+			buildErr = err
+			build.CleanSource(repoDir, stageDir, logFn)
+			continue
+		}
+		info.Rustc = rustc
+
+		if !skipDeps && !*flagStaticDeps {
+			ensureSourceControlDeps(workDir, repoDir, repo.Name, targetArch, crossTarget != nil, logFn)
+		}
+
+		if !*flagNoPreflight {
+			if err := preflightComponent(workDir, repoDir, repo, targetArch, crossTarget != nil, !skipDeps, logFn); err != nil {
+				log("ERROR: Pre-flight failed for %s: %v", repo.Name, err)
+				buildErr = err
+				build.CleanSource(repoDir, stageDir, logFn)
+				continue
+			}
+		}
+
+		buildSpec := ""
+		if repo.Build != nil {
+			buildSpec = repo.Build.System
+		}
+		sys, err := build.SelectBuildSystem(repoDir, buildSpec)
+		if err != nil {
+			log("ERROR: Cannot select build system for %s: %v", repo.Name, err)
+			buildErr = err
+			build.CleanSource(repoDir, stageDir, logFn)
+			continue
+		}
+		logVerbose(verbose, "Using %s build system for %s", sys.Name(), repo.Name)
+		if repo.Build != nil && repo.Build.SkipTests && !build.ProducesPackages(sys) {
+			log("WARNING: skip_tests has no effect for %s: the %s build system runs no tests", repo.Name, sys.Name())
+		}
+		if !skipDeps {
+			ensureSystemDeps(workDir, sys.Name(), repo.Name, logFn)
+		}
+
+		if build.ProducesPackages(sys) {
+			if err := build.RunVendor(sys, repo.Build, repoDir, workDir, logFn); err != nil {
+				log("WARNING: Vendoring failed for %s: %v", repo.Name, err)
+			}
+			if err := auditComponent(advisoryDB, auditThreshold, repoDir, repo.Name, logFn); err != nil {
+				log("ERROR: Audit failed for %s: %v", repo.Name, err)
+				buildErr = err
+				build.CleanSource(repoDir, stageDir, logFn)
+				continue
+			}
+			if !*flagNoSBOM {
+				stageSourceSBOM(repoDir, repo.Name, build.GetVersion(repoDir, effectiveTag), logFn)
+			}
+			if err := build.Compile(sys, repo.Build, repoDir, repo.Name, workDir, outDir, jobs, logFn); err != nil {
+				fallback, ferr := build.DetectBuildSystem(repoDir, sys.Name())
+				if ferr != nil {
+					log("ERROR: %s build failed for %s: %v", sys.Name(), repo.Name, err)
+					buildErr = err
+					build.CleanSource(repoDir, stageDir, logFn)
+					continue
+				}
+				log("WARNING: %s build failed for %s: %v; attempting %s build", sys.Name(), repo.Name, err, fallback.Name())
+				sys = fallback
+				if !skipDeps {
+					ensureSystemDeps(workDir, sys.Name(), repo.Name, logFn)
+				}
 			} else {
 				builtPkgs = append(builtPkgs, repo.Name)
+				builtDebs = append(builtDebs, build.CompiledDebs()...)
 				successfulBuilds++
-				logVerbose(verbose, "Component %s built via debian/ path successfully", repo.Name)
+				logVerbose(verbose, "Component %s built via %s successfully", repo.Name, sys.Name())
+				info.BuildSystem = sys.Name()
+				info.Version = build.GetVersion(repoDir, effectiveTag)
+				info.Linker = ""
+				writeBuildInfo(info, "", outDir, nameCodename, logFn)
+				if !*flagNoSBOM {
+					writeSBOM(repoDir, "", outDir, repo.Name, info.Version, nameCodename, logFn)
+				}
 				build.CleanSource(repoDir, stageDir, logFn)
 				logVerbose(verbose, "Cleaned source and staging for %s", repo.Name)
 				if thermalEnabled {
@@ -350,9 +705,18 @@ func main() {
 			}
 		}
 
-		build.RunVendor(repoDir, workDir, logFn)
+		if err := build.RunVendor(sys, repo.Build, repoDir, workDir, logFn); err != nil {
+			log("WARNING: Vendoring failed for %s: %v", repo.Name, err)
+		}
+
+		if err := auditComponent(advisoryDB, auditThreshold, repoDir, repo.Name, logFn); err != nil {
+			log("ERROR: Audit failed for %s: %v", repo.Name, err)
+			buildErr = err
+			build.CleanSource(repoDir, stageDir, logFn)
+			continue
+		}
 
-		if err := build.Compile(repoDir, repo.Name, workDir, jobs, logFn); err != nil {
+		if err := compileWithDiagnosis(sys, repo.Build, repoDir, repo.Name, workDir, outDir, jobs, *flagAutoInstall && !skipDeps, logFn); err != nil {
 			log("ERROR: Compilation failed for %s: %v", repo.Name, err)
 			buildErr = err
 			build.CleanSource(repoDir, stageDir, logFn)
@@ -376,20 +740,57 @@ func main() {
 			continue
 		}
 
-		if err := build.InstallToStage(repoDir, stageDir, workDir); err != nil {
+		if err := build.InstallToStage(sys, repo.Build, repoDir, stageDir, workDir, logFn); err != nil {
 			log("WARNING: Staging install failed for %s: %v", repo.Name, err)
 		}
 
 		if debian.StagingHasContent(stageDir) {
 			logVerbose(verbose, "Staging directory has content; building .deb for %s", repo.Name)
-			if err := debian.BuildPackage(stageDir, outDir, repo.Name, version, di.Codename, maintainerName, maintainerEmail); err != nil {
+			info.BuildSystem = sys.Name()
+			if sys.Name() == "just" {
+				info.Just = build.JustVersion()
+			}
+			info.Version = version
+			writeBuildInfo(info, stageDir, outDir, nameCodename, logFn)
+			if !*flagNoSBOM {
+				writeSBOM(repoDir, stageDir, outDir, repo.Name, version, nameCodename, logFn)
+			}
+			var splitDeps, splitRecs []string
+			if rules := splitRules(repo); len(rules) > 0 {
+				subs, err := debian.SplitStage(stageDir, workDir, rules)
+				if err != nil {
+					log("ERROR: Package splitting failed for %s: %v", repo.Name, err)
+					build.CleanSource(repoDir, stageDir, logFn)
+					continue
+				}
+				built, err := buildSplitPackages(subs, stageDir, outDir, repo.Name, version, targetArch, nameCodename, maintainerName, maintainerEmail, logFn)
+				if err != nil {
+					log("ERROR: Package splitting failed for %s: %v", repo.Name, err)
+					build.CleanSource(repoDir, stageDir, logFn)
+					continue
+				}
+				for _, sub := range built {
+					builtPkgs = append(builtPkgs, sub.Rule.Package)
+					builtDebs = append(builtDebs, sub.Deb)
+				}
+				splitDeps = debian.SplitDepends(built, version, nameCodename)
+				splitRecs = debian.SplitRecommends(built)
+			}
+			pkgArch, err := debian.ResolveArch(stageDir, targetArch)
+			if err != nil {
+				log("WARNING: Architecture check for %s: %v", repo.Name, err)
+			}
+			logVerbose(verbose, "Resolved package architecture for %s: %s", repo.Name, pkgArch)
+			deb, err := debian.BuildPackage(stageDir, outDir, repo.Name, version, pkgArch, nameCodename, maintainerName, maintainerEmail, packageOverrides(repo, patches), splitDeps, splitRecs)
+			if err != nil {
 				log("ERROR: .deb assembly failed for %s: %v", repo.Name, err)
 				build.CleanSource(repoDir, stageDir, logFn)
 				continue
 			}
 			builtPkgs = append(builtPkgs, repo.Name)
+			builtDebs = append(builtDebs, deb)
 			successfulBuilds++
-			log("[%d/%d] Packaged: %s %s~%s", i+1, total, repo.Name, version, di.Codename)
+			log("[%d/%d] Packaged: %s %s~%s (%s)", i+1, total, repo.Name, version, nameCodename, pkgArch)
 		} else {
 			log("WARNING: Empty staging directory for %s; skipping .deb assembly", repo.Name)
 		}
@@ -409,14 +810,16 @@ func main() {
 			metaVersion = strings.TrimPrefix(metaVersion, "v")
 		}
 		logVerbose(verbose, "Building cosmic-desktop meta-package (version=%s, deps=%d)", metaVersion, len(builtPkgs))
-		if err := debian.BuildMetaPackage(workDir, outDir, metaVersion, di.Codename, maintainerName, maintainerEmail, builtPkgs); err != nil {
+		if deb, err := debian.BuildMetaPackage(workDir, outDir, metaVersion, nameCodename, maintainerName, maintainerEmail, builtPkgs); err != nil {
 			log("WARNING: Meta-package assembly failed: %v", err)
 		} else {
+			builtDebs = append(builtDebs, deb)
 			log("Meta-package cosmic-desktop built successfully")
 		}
 	}
 
 	log("Build summary: %d/%d components packaged successfully", len(builtPkgs), total)
+	build.SccacheReport(func(f string, a ...any) { log(f, a...) })
 	if len(builtPkgs) > 0 {
 		log("Output directory: %s", outDir)
 		logVerbose(verbose, "Built packages: %s", strings.Join(builtPkgs, ", "))
@@ -426,23 +829,417 @@ func main() {
 		log("Build completed with errors")
 	}
 
-	if !distro.IsContainer() && len(builtPkgs) > 0 {
+	if !hostEnv.InContainer() && !hostEnv.Chroot && crossTarget == nil && len(builtPkgs) > 0 {
 		fmt.Printf("\nInstall the built packages now? [y/N] ")
 		var answer string
 		fmt.Scanln(&answer)
 		if strings.ToLower(answer) == "y" {
-			log("Running install-local.sh")
-			if err := execShell("bash", filepath.Join("scripts", "install-local.sh"), outDir); err != nil {
-				log("WARNING: Installation script failed: %v", err)
+			if err := build.InstallBuiltPackages(builtDebs, logFn); err != nil {
+				log("WARNING: Installation failed: %v", err)
 			}
 		}
 	}
 
+	if *flagPurgeDeps && !skipDeps {
+		if err := build.PurgeInstalledDeps(workDir, logFn); err != nil {
+			log("WARNING: Purging build dependencies failed: %v", err)
+		}
+	}
+
 	if useTUIMonitor {
 		doneCh <- tui.DoneMsg{Err: buildErr}
 	}
 }
 
+func controlDeps(perComponent map[string][]string, entries []repos.Entry, globalTag string, useBranch bool, hostArch string, logFn func(string, ...any)) {
+	for _, repo := range entries {
+		tag := repos.EffectiveTag(repo, globalTag)
+		if useBranch {
+			tag = ""
+		}
+		control, err := build.FetchControl(repo, tag)
+		if err != nil {
+			logFn("WARNING: %v; using static build dependencies", err)
+			continue
+		}
+		pkgs, unresolved, err := distro.BuildDepsFromControl(control, hostArch)
+		if err != nil {
+			logFn("WARNING: Cannot parse debian/control for %s: %v; using static build dependencies", repo.Name, err)
+			continue
+		}
+		for _, g := range unresolved {
+			logFn("WARNING: %s: no installable candidate for Build-Depends '%s'", repo.Name, g)
+		}
+		perComponent[repo.Name] = pkgs
+	}
+}
+
+func addExtraDeps(perComponent map[string][]string, entries []repos.Entry) {
+	for _, repo := range entries {
+		if repo.Build == nil {
+			continue
+		}
+		perComponent[repo.Name] = append(perComponent[repo.Name], build.SystemBuildDeps(repo.Build.System)...)
+		perComponent[repo.Name] = append(perComponent[repo.Name], repo.Build.ExtraDeps...)
+	}
+}
+
+func explainDeps(owners map[string][]string) {
+	var pkgs []string
+	width := 0
+	for pkg := range owners {
+		pkgs = append(pkgs, pkg)
+		if len(pkg) > width {
+			width = len(pkg)
+		}
+	}
+	sort.Strings(pkgs)
+	for _, pkg := range pkgs {
+		fmt.Printf("%-*s  %s\n", width, pkg, strings.Join(owners[pkg], ", "))
+	}
+}
+
+func packageOverrides(repo repos.Entry, patches []string) debian.Overrides {
+	ov := debian.Overrides{Patches: patches}
+	if repo.Build != nil && repo.Build.Packaging != nil {
+		p := repo.Build.Packaging
+		ov.Depends = p.Depends
+		ov.Recommends = p.Recommends
+		ov.Conflicts = p.Conflicts
+		ov.Section = p.Section
+		ov.Description = p.Description
+	}
+	return ov
+}
+
+func splitRules(repo repos.Entry) []debian.SplitRule {
+	if repo.Build == nil || repo.Build.Packaging == nil || len(repo.Build.Packaging.Split) == 0 {
+		return debian.SplitRules[repo.Name]
+	}
+	var rules []debian.SplitRule
+	for _, r := range repo.Build.Packaging.Split {
+		rules = append(rules, debian.SplitRule{
+			Package:          r.Package,
+			Patterns:         r.Patterns,
+			Depends:          r.Depends,
+			Recommends:       r.Recommends,
+			Section:          r.Section,
+			Description:      r.Description,
+			ParentDepends:    r.ParentDepends,
+			ParentRecommends: r.ParentRecommends,
+			DependsOnParent:  r.DependsOnParent,
+		})
+	}
+	return rules
+}
+
+func compileWithDiagnosis(sys build.BuildSystem, spec *repos.BuildSpec, repoDir, name, workDir, outDir string, jobs int, autoInstall bool, logFn func(string, ...any)) error {
+	err := build.Compile(sys, spec, repoDir, name, workDir, outDir, jobs, logFn)
+	var cerr *build.CompileError
+	if !errors.As(err, &cerr) || cerr.Diagnosis == nil {
+		return err
+	}
+	cerr.Diagnosis.Log(name, logFn)
+	if !autoInstall || len(cerr.Diagnosis.Packages) == 0 {
+		return err
+	}
+	pkgs, unavailable := distro.ResolveAlternatives(cerr.Diagnosis.Packages)
+	if len(unavailable) > 0 {
+		return err
+	}
+	missing := build.CheckPackagesInstalled(pkgs)
+	if len(missing) == 0 {
+		return err
+	}
+	logFn("Installing %s and retrying %s once", strings.Join(missing, ", "), name)
+	if ierr := build.InstallPackages(workDir, missing, logFn); ierr != nil {
+		logFn("WARNING: Remediation failed for %s: %v", name, ierr)
+		return err
+	}
+	err = build.Compile(sys, spec, repoDir, name, workDir, outDir, jobs, logFn)
+	if errors.As(err, &cerr) && cerr.Diagnosis != nil {
+		cerr.Diagnosis.Log(name, logFn)
+	}
+	return err
+}
+
+func preflightComponent(workDir, repoDir string, repo repos.Entry, hostArch string, cross, install bool, logFn func(string, ...any)) error {
+	name := repo.Name
+	var spec repos.BuildSpec
+	if repo.Build != nil {
+		spec = *repo.Build
+	}
+	triple := ""
+	if t, err := build.CrossTargetFor(hostArch); err == nil {
+		triple = t.RustTriple
+	}
+	cargoArgs := build.CargoFeatureArgs(spec)
+	missing, err := distro.Preflight(repoDir, triple, cargoArgs)
+	if err != nil {
+		logFn("WARNING: Pre-flight check skipped for %s: %v", name, err)
+		return nil
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	for _, r := range missing {
+		logFn("Pre-flight: %s: missing %s", name, r)
+	}
+	if !hasResolvedRequirement(missing) {
+		logFn("WARNING: Pre-flight for %s could not resolve the crates that will be built; continuing", name)
+	}
+	pkgs, unknown := distro.RequirementPackages(missing)
+	if install && len(unknown) == 0 {
+		pkgs, unavailable := distro.ResolveAlternatives(pkgs)
+		if len(unavailable) == 0 {
+			if cross {
+				pkgs = distro.CrossBuildDeps(pkgs, hostArch)
+			}
+			logFn("Installing %d packages required by %s: %s", len(pkgs), name, strings.Join(pkgs, ", "))
+			if err := build.InstallPackages(workDir, pkgs, logFn); err != nil {
+				if !hasResolvedRequirement(missing) {
+					logFn("WARNING: Cannot install packages for %s: %v", name, err)
+					return nil
+				}
+				return err
+			}
+			if missing, err = distro.Preflight(repoDir, triple, cargoArgs); err != nil || len(missing) == 0 {
+				return nil
+			}
+			pkgs, _ = distro.RequirementPackages(missing)
+		}
+	}
+	if !hasResolvedRequirement(missing) {
+		return nil
+	}
+	if len(pkgs) > 0 {
+		return fmt.Errorf("missing system dependencies; install: %s", strings.Join(pkgs, " "))
+	}
+	return fmt.Errorf("missing system dependencies with no known providing package")
+}
+
+func hasResolvedRequirement(reqs []distro.Requirement) bool {
+	for _, r := range reqs {
+		if !r.LockfileOnly {
+			return true
+		}
+	}
+	return false
+}
+
+func ensureSourceControlDeps(workDir, repoDir, name, hostArch string, cross bool, logFn func(string, ...any)) {
+	data, err := os.ReadFile(filepath.Join(repoDir, "debian", "control"))
+	if err != nil {
+		return
+	}
+	pkgs, unresolved, err := distro.BuildDepsFromControl(string(data), hostArch)
+	if err != nil {
+		logFn("WARNING: Cannot parse debian/control for %s: %v", name, err)
+		return
+	}
+	for _, g := range unresolved {
+		logFn("WARNING: %s: no installable candidate for Build-Depends '%s'", name, g)
+	}
+	if cross {
+		pkgs = distro.CrossBuildDeps(pkgs, hostArch)
+	}
+	missing := build.CheckPackagesInstalled(pkgs)
+	if len(missing) == 0 {
+		return
+	}
+	logFn("Installing %d Build-Depends of %s missing from the host: %s", len(missing), name, strings.Join(missing, ", "))
+	if err := build.InstallPackages(workDir, missing, logFn); err != nil {
+		logFn("WARNING: Build-Depends installation failed for %s: %v", name, err)
+	}
+}
+
+func ensureSystemDeps(workDir, system, name string, logFn func(string, ...any)) {
+	missing := build.CheckPackagesInstalled(build.SystemBuildDeps(system))
+	if len(missing) == 0 {
+		return
+	}
+	logFn("Installing %s build system tools for %s: %s", system, name, strings.Join(missing, ", "))
+	if err := build.InstallPackages(workDir, missing, logFn); err != nil {
+		logFn("WARNING: %s build system tools installation failed for %s: %v", system, name, err)
+	}
+}
+
+func buildSplitPackages(subs []debian.SubPackage, stageDir, outDir, parent, version, targetArch, codename, maintainerName, maintainerEmail string, logFn func(string, ...any)) ([]debian.SubPackage, error) {
+	var built []debian.SubPackage
+	for _, sub := range subs {
+		arch, err := debian.ResolveArch(sub.StageDir, targetArch)
+		if err != nil {
+			logFn("WARNING: Architecture check for %s: %v", sub.Rule.Package, err)
+		}
+		deb, err := debian.BuildSubPackage(sub, outDir, parent, version, arch, codename, maintainerName, maintainerEmail)
+		if err != nil {
+			logFn("ERROR: .deb assembly failed for split package %s: %v; keeping its %d files in %s", sub.Rule.Package, err, sub.Files, parent)
+			if err := debian.MergeStage(sub, stageDir); err != nil {
+				return built, err
+			}
+			continue
+		}
+		logFn("Split package built: %s (%d files, %s) from %s", sub.Rule.Package, sub.Files, arch, parent)
+		sub.Deb = deb
+		built = append(built, sub)
+		os.RemoveAll(sub.StageDir)
+	}
+	return built, nil
+}
+
+func auditComponent(db *audit.Database, threshold audit.Severity, repoDir, name string, logFn func(string, ...any)) error {
+	if db == nil {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, "Cargo.lock")); err != nil {
+		logFn("Audit: %s has no Cargo.lock; skipping", name)
+		return nil
+	}
+	report, err := audit.AuditSource(db, repoDir, name)
+	if err != nil {
+		logFn("WARNING: Audit could not read Cargo.lock for %s: %v", name, err)
+		return nil
+	}
+	report.Log(logFn)
+	if threshold == audit.SeverityUnknown {
+		return nil
+	}
+	if over := report.Exceeds(threshold); len(over) > 0 {
+		return fmt.Errorf("%d advisories at or above %s severity", len(over), threshold)
+	}
+	return nil
+}
+
+func applyPatchQueue(repoDir, name string, logFn func(string, ...any)) ([]string, error) {
+	queue, err := build.PatchQueue(*flagPatches, name)
+	if err != nil || len(queue) == 0 {
+		return nil, err
+	}
+	logFn("Applying %d patch(es) to %s from %s", len(queue), name, filepath.Join(*flagPatches, name))
+	results, err := build.ApplyPatches(repoDir, queue, false, logFn)
+	if err != nil {
+		return nil, err
+	}
+	return build.AppliedPatches(results), nil
+}
+
+func checkPatches(entries []repos.Entry, globalTag, workDir string, logFn func(string, ...any)) error {
+	failed := 0
+	checked := 0
+	for _, repo := range entries {
+		queue, err := build.PatchQueue(*flagPatches, repo.Name)
+		if err != nil {
+			logFn("ERROR: %s: %v", repo.Name, err)
+			failed++
+			continue
+		}
+		if len(queue) == 0 {
+			continue
+		}
+		tag := repos.EffectiveTag(repo, globalTag)
+		if *flagUseBranch {
+			tag = ""
+		}
+		checked++
+		logFn("Checking %d patch(es) for %s against %q", len(queue), repo.Name, tag)
+		repoDir := build.DownloadSource(workDir, repo, tag, logFn)
+		if _, err := build.ApplyPatches(repoDir, queue, true, logFn); err != nil {
+			logFn("ERROR: %s: %v", repo.Name, err)
+			failed++
+		}
+		build.CleanSource(repoDir, "", logFn)
+	}
+	if failed > 0 {
+		return fmt.Errorf("patch queues of %d component(s) no longer apply", failed)
+	}
+	if checked == 0 {
+		logFn("No patch queues found under %s for the selected components", *flagPatches)
+	} else {
+		logFn("All %d patch queue(s) apply cleanly", checked)
+	}
+	return nil
+}
+
+func writeBuildInfo(info *build.BuildInfo, stageDir, outDir, codename string, logFn func(string, ...any)) {
+	if st, ok := build.ComponentCacheStats(info.Component); ok {
+		info.Sccache = fmt.Sprintf("sccache %s: %d/%d hits (%.1f%%)", build.SccacheVersion(), st.Hits, st.Hits+st.Misses, st.HitRate())
+	}
+	if stageDir != "" {
+		if err := info.Write(filepath.Join(stageDir, "usr", "share", "doc", info.Component, "build-info.json")); err != nil {
+			logFn("WARNING: Failed to stage build info for %s: %v", info.Component, err)
+		}
+	}
+	outPath := filepath.Join(outDir, fmt.Sprintf("%s_%s%s", info.Component, debian.FileVersion(info.Version, codename), build.BuildInfoSuffix))
+	if err := info.Write(outPath); err != nil {
+		logFn("WARNING: Failed to write build info for %s: %v", info.Component, err)
+	}
+}
+
+func writeSBOM(repoDir, stageDir, outDir, pkgName, version, codename string, logFn func(string, ...any)) {
+	doc, err := sbom.FromSource(repoDir, pkgName, version)
+	if err != nil {
+		logFn("WARNING: SBOM generation skipped for %s: %v", pkgName, err)
+		return
+	}
+	if stageDir != "" {
+		docPath := filepath.Join(stageDir, "usr", "share", "doc", pkgName, "sbom"+sbom.FileSuffix)
+		if err := doc.Write(docPath); err != nil {
+			logFn("WARNING: Failed to stage SBOM for %s: %v", pkgName, err)
+		}
+	}
+	outPath := filepath.Join(outDir, fmt.Sprintf("%s_%s%s", pkgName, debian.FileVersion(version, codename), sbom.FileSuffix))
+	if err := doc.Write(outPath); err != nil {
+		logFn("WARNING: Failed to write SBOM for %s: %v", pkgName, err)
+		return
+	}
+	logFn("SBOM written for %s: %d crates (%s)", pkgName, len(doc.Components), filepath.Base(outPath))
+}
+
+func stageSourceSBOM(repoDir, pkgName, version string, logFn func(string, ...any)) {
+	doc, err := sbom.FromSource(repoDir, pkgName, version)
+	if err != nil {
+		logFn("WARNING: SBOM generation skipped for %s: %v", pkgName, err)
+		return
+	}
+	debDir := filepath.Join(repoDir, "debian")
+	control, err := os.ReadFile(filepath.Join(debDir, "control"))
+	if err != nil {
+		logFn("WARNING: Cannot read debian/control for %s: %v", pkgName, err)
+		return
+	}
+	binPkg := ""
+	for _, line := range strings.Split(string(control), "\n") {
+		if v, ok := strings.CutPrefix(line, "Package:"); ok {
+			binPkg = strings.TrimSpace(v)
+			break
+		}
+	}
+	if binPkg == "" {
+		logFn("WARNING: debian/control of %s declares no binary package; SBOM not packaged", pkgName)
+		return
+	}
+	name := "sbom" + sbom.FileSuffix
+	if err := doc.Write(filepath.Join(debDir, name)); err != nil {
+		logFn("WARNING: Failed to stage SBOM for %s: %v", pkgName, err)
+		return
+	}
+	docsPath := filepath.Join(debDir, binPkg+".docs")
+	if _, err := os.Stat(docsPath); err != nil {
+		if _, err := os.Stat(filepath.Join(debDir, "docs")); err == nil {
+			docsPath = filepath.Join(debDir, "docs")
+		}
+	}
+	f, err := os.OpenFile(docsPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		logFn("WARNING: Failed to register SBOM in %s: %v", filepath.Base(docsPath), err)
+		return
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintf(f, "\ndebian/%s\n", name); err != nil {
+		logFn("WARNING: Failed to register SBOM in %s: %v", filepath.Base(docsPath), err)
+	}
+}
+
 func interactiveSelectTag(cfg *repos.Config, verbose bool) string {
 	tags := repos.EpochTags(cfg)
 	fmt.Println("Select build source:")
@@ -0,0 +1,208 @@
+package audit
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Advisory struct {
+	ID            string
+	Package       string
+	Title         string
+	CVSS          string
+	Informational string
+	Withdrawn     string
+	Aliases       []string
+	Patched       []Requirement
+	Unaffected    []Requirement
+	PatchedRaw    []string
+}
+
+type Database struct {
+	Path       string
+	byPackage  map[string][]Advisory
+	Advisories int
+}
+
+func LoadDatabase(dir string) (*Database, error) {
+	cratesDir := filepath.Join(dir, "crates")
+	if info, err := os.Stat(cratesDir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("'%s' does not look like a RustSec advisory-db checkout (missing crates/)", dir)
+	}
+	db := &Database{Path: dir, byPackage: make(map[string][]Advisory)}
+	err := filepath.WalkDir(cratesDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if !strings.HasSuffix(path, ".md") && !strings.HasSuffix(path, ".toml") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		adv, err := parseAdvisory(string(data), strings.HasSuffix(path, ".md"))
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if adv.Withdrawn != "" {
+			return nil
+		}
+		db.byPackage[adv.Package] = append(db.byPackage[adv.Package], adv)
+		db.Advisories++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+func (db *Database) For(crate string) []Advisory {
+	return db.byPackage[crate]
+}
+
+func (a Advisory) Affects(v Version) bool {
+	for _, r := range a.Unaffected {
+		if r.Matches(v) {
+			return false
+		}
+	}
+	for _, r := range a.Patched {
+		if r.Matches(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a Advisory) Severity() (Severity, float64) {
+	if a.CVSS == "" {
+		return SeverityUnknown, 0
+	}
+	score, err := CVSS3BaseScore(a.CVSS)
+	if err != nil {
+		return SeverityUnknown, 0
+	}
+	return SeverityFromScore(score), score
+}
+
+func parseAdvisory(content string, markdown bool) (Advisory, error) {
+	var adv Advisory
+	body := content
+	if markdown {
+		start := strings.Index(content, "```toml")
+		if start < 0 {
+			return adv, fmt.Errorf("missing TOML front matter")
+		}
+		rest := content[start+len("```toml"):]
+		end := strings.Index(rest, "```")
+		if end < 0 {
+			return adv, fmt.Errorf("unterminated TOML front matter")
+		}
+		body = rest[:end]
+		for _, line := range strings.Split(rest[end+3:], "\n") {
+			if strings.HasPrefix(line, "# ") {
+				adv.Title = strings.TrimSpace(strings.TrimPrefix(line, "# "))
+				break
+			}
+		}
+	}
+
+	section := ""
+	lines := strings.Split(body, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			section = strings.Trim(line, "[] ")
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		val = strings.TrimSpace(val)
+		if strings.HasPrefix(val, "[") {
+			for !strings.Contains(val, "]") && i+1 < len(lines) {
+				i++
+				val += " " + strings.TrimSpace(lines[i])
+			}
+		}
+		switch section + "." + key {
+		case "advisory.id":
+			adv.ID = tomlString(val)
+		case "advisory.package":
+			adv.Package = tomlString(val)
+		case "advisory.title":
+			adv.Title = tomlString(val)
+		case "advisory.cvss":
+			adv.CVSS = tomlString(val)
+		case "advisory.informational":
+			adv.Informational = tomlString(val)
+		case "advisory.withdrawn":
+			adv.Withdrawn = tomlString(val)
+		case "advisory.aliases":
+			adv.Aliases = tomlStringArray(val)
+		case "versions.patched":
+			adv.PatchedRaw = tomlStringArray(val)
+			reqs, err := parseRequirements(adv.PatchedRaw)
+			if err != nil {
+				return adv, err
+			}
+			adv.Patched = reqs
+		case "versions.unaffected":
+			reqs, err := parseRequirements(tomlStringArray(val))
+			if err != nil {
+				return adv, err
+			}
+			adv.Unaffected = reqs
+		}
+	}
+	if adv.ID == "" || adv.Package == "" {
+		return adv, fmt.Errorf("advisory is missing id or package")
+	}
+	return adv, nil
+}
+
+func parseRequirements(raw []string) ([]Requirement, error) {
+	var reqs []Requirement
+	for _, r := range raw {
+		req, err := ParseRequirement(r)
+		if err != nil {
+			return nil, err
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs, nil
+}
+
+func tomlString(val string) string {
+	if idx := strings.Index(val, " #"); idx >= 0 && !strings.HasPrefix(val, `"`) {
+		val = val[:idx]
+	}
+	return strings.Trim(strings.TrimSpace(val), `"'`)
+}
+
+func tomlStringArray(val string) []string {
+	val = strings.TrimSpace(val)
+	val = strings.TrimPrefix(val, "[")
+	if idx := strings.LastIndex(val, "]"); idx >= 0 {
+		val = val[:idx]
+	}
+	var out []string
+	for _, item := range strings.Split(val, `"`) {
+		item = strings.TrimSpace(item)
+		if item == "" || item == "," {
+			continue
+		}
+		out = append(out, item)
+	}
+	return out
+}
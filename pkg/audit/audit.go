@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jimed-rand/cosmic-deb/pkg/cargo"
+)
+
+type Finding struct {
+	Advisory Advisory
+	Crate    string
+	Version  string
+	Severity Severity
+	Score    float64
+}
+
+type Report struct {
+	Component string
+	Crates    int
+	Findings  []Finding
+}
+
+func AuditSource(db *Database, repoDir, component string) (*Report, error) {
+	lock, err := cargo.ParseLock(filepath.Join(repoDir, "Cargo.lock"))
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Component: component}
+	for _, p := range lock.Packages {
+		if !p.IsCratesIO() {
+			continue
+		}
+		report.Crates++
+		v, err := ParseVersion(p.Version)
+		if err != nil {
+			continue
+		}
+		for _, adv := range db.For(p.Name) {
+			if !adv.Affects(v) {
+				continue
+			}
+			sev, score := adv.Severity()
+			report.Findings = append(report.Findings, Finding{
+				Advisory: adv,
+				Crate:    p.Name,
+				Version:  p.Version,
+				Severity: sev,
+				Score:    score,
+			})
+		}
+	}
+	sort.Slice(report.Findings, func(i, j int) bool {
+		a, b := report.Findings[i], report.Findings[j]
+		if a.Severity != b.Severity {
+			return a.Severity > b.Severity
+		}
+		return a.Advisory.ID < b.Advisory.ID
+	})
+	return report, nil
+}
+
+func (r *Report) Exceeds(threshold Severity) []Finding {
+	var over []Finding
+	for _, f := range r.Findings {
+		if f.Advisory.Informational != "" {
+			continue
+		}
+		if f.Severity >= threshold && f.Severity != SeverityUnknown {
+			over = append(over, f)
+		}
+	}
+	return over
+}
+
+func (r *Report) Log(logFn func(string, ...any)) {
+	if len(r.Findings) == 0 {
+		logFn("Audit: %s — %d crates checked, no known advisories", r.Component, r.Crates)
+		return
+	}
+	logFn("Audit: %s — %d crates checked, %d advisories", r.Component, r.Crates, len(r.Findings))
+	for _, f := range r.Findings {
+		label := f.Severity.String()
+		if f.Score > 0 {
+			label = fmt.Sprintf("%s %.1f", label, f.Score)
+		}
+		if f.Advisory.Informational != "" {
+			label = f.Advisory.Informational
+		}
+		patched := "no patched release"
+		if len(f.Advisory.PatchedRaw) > 0 {
+			patched = "patched: " + strings.Join(f.Advisory.PatchedRaw, " | ")
+		}
+		logFn("  %s %s %s (%s): %s; %s", f.Advisory.ID, f.Crate, f.Version, label, f.Advisory.Title, patched)
+	}
+}
@@ -0,0 +1,113 @@
+package audit
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityUnknown Severity = iota
+	SeverityNone
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = map[Severity]string{
+	SeverityUnknown:  "unknown",
+	SeverityNone:     "none",
+	SeverityLow:      "low",
+	SeverityMedium:   "medium",
+	SeverityHigh:     "high",
+	SeverityCritical: "critical",
+}
+
+func (s Severity) String() string {
+	return severityNames[s]
+}
+
+func ParseSeverity(s string) (Severity, error) {
+	for _, sev := range []Severity{SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical} {
+		if strings.EqualFold(s, sev.String()) {
+			return sev, nil
+		}
+	}
+	return SeverityUnknown, fmt.Errorf("unknown severity %q (expected low, medium, high or critical)", s)
+}
+
+func SeverityFromScore(score float64) Severity {
+	switch {
+	case score >= 9.0:
+		return SeverityCritical
+	case score >= 7.0:
+		return SeverityHigh
+	case score >= 4.0:
+		return SeverityMedium
+	case score > 0:
+		return SeverityLow
+	}
+	return SeverityNone
+}
+
+func CVSS3BaseScore(vector string) (float64, error) {
+	parts := strings.Split(vector, "/")
+	if len(parts) == 0 || !strings.HasPrefix(parts[0], "CVSS:3.") {
+		return 0, fmt.Errorf("unsupported CVSS vector %q", vector)
+	}
+	m := make(map[string]string)
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, ":"); ok {
+			m[k] = v
+		}
+	}
+	changed := m["S"] == "C"
+	weights := map[string]map[string]float64{
+		"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+		"AC": {"L": 0.77, "H": 0.44},
+		"UI": {"N": 0.85, "R": 0.62},
+		"C":  {"H": 0.56, "L": 0.22, "N": 0},
+		"I":  {"H": 0.56, "L": 0.22, "N": 0},
+		"A":  {"H": 0.56, "L": 0.22, "N": 0},
+	}
+	if changed {
+		weights["PR"] = map[string]float64{"N": 0.85, "L": 0.68, "H": 0.5}
+	} else {
+		weights["PR"] = map[string]float64{"N": 0.85, "L": 0.62, "H": 0.27}
+	}
+	val := make(map[string]float64)
+	for metric, table := range weights {
+		w, ok := table[m[metric]]
+		if !ok {
+			return 0, fmt.Errorf("CVSS vector %q is missing or has invalid metric %s", vector, metric)
+		}
+		val[metric] = w
+	}
+
+	iss := 1 - (1-val["C"])*(1-val["I"])*(1-val["A"])
+	var impact float64
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	} else {
+		impact = 6.42 * iss
+	}
+	if impact <= 0 {
+		return 0, nil
+	}
+	exploitability := 8.22 * val["AV"] * val["AC"] * val["PR"] * val["UI"]
+	if changed {
+		return roundUp(math.Min(1.08*(impact+exploitability), 10)), nil
+	}
+	return roundUp(math.Min(impact+exploitability, 10)), nil
+}
+
+func roundUp(x float64) float64 {
+	i := int64(math.Round(x * 100000))
+	if i%10000 == 0 {
+		return float64(i) / 100000
+	}
+	return float64(i/10000+1) / 10
+}
@@ -0,0 +1,79 @@
+package audit
+
+import "testing"
+
+func TestCVSS3BaseScore(t *testing.T) {
+	tests := []struct {
+		vector  string
+		want    float64
+		wantErr bool
+	}{
+		{vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", want: 9.8},
+		{vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:C/C:H/I:H/A:H", want: 10.0},
+		{vector: "CVSS:3.0/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N", want: 6.1},
+		{vector: "CVSS:3.1/AV:L/AC:L/PR:L/UI:N/S:U/C:H/I:N/A:N", want: 5.5},
+		{vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:H", want: 7.5},
+		{vector: "CVSS:3.1/AV:P/AC:H/PR:H/UI:R/S:U/C:L/I:N/A:N", want: 1.6},
+		{vector: "CVSS:3.1/AV:N/AC:H/PR:N/UI:N/S:U/C:N/I:N/A:N", want: 0},
+		{vector: "CVSS:2.0/AV:N/AC:L/Au:N/C:P/I:P/A:P", wantErr: true},
+		{vector: "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H", wantErr: true},
+		{vector: "CVSS:3.1/AV:X/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := CVSS3BaseScore(tt.vector)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CVSS3BaseScore(%q) error = %v, wantErr %v", tt.vector, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("CVSS3BaseScore(%q) = %v, want %v", tt.vector, got, tt.want)
+		}
+	}
+}
+
+func TestSeverityFromScore(t *testing.T) {
+	tests := []struct {
+		score float64
+		want  Severity
+	}{
+		{0, SeverityNone},
+		{0.1, SeverityLow},
+		{3.9, SeverityLow},
+		{4.0, SeverityMedium},
+		{6.9, SeverityMedium},
+		{7.0, SeverityHigh},
+		{8.9, SeverityHigh},
+		{9.0, SeverityCritical},
+		{10.0, SeverityCritical},
+	}
+	for _, tt := range tests {
+		if got := SeverityFromScore(tt.score); got != tt.want {
+			t.Errorf("SeverityFromScore(%v) = %v, want %v", tt.score, got, tt.want)
+		}
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Severity
+		wantErr bool
+	}{
+		{in: "low", want: SeverityLow},
+		{in: "Medium", want: SeverityMedium},
+		{in: "HIGH", want: SeverityHigh},
+		{in: "critical", want: SeverityCritical},
+		{in: "none", wantErr: true},
+		{in: "severe", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSeverity(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSeverity(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSeverity(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
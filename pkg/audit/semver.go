@@ -0,0 +1,219 @@
+package audit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Version struct {
+	Major, Minor, Patch int
+	Pre                 string
+}
+
+func ParseVersion(s string) (Version, error) {
+	s = strings.TrimSpace(s)
+	if idx := strings.Index(s, "+"); idx >= 0 {
+		s = s[:idx]
+	}
+	var v Version
+	core := s
+	if idx := strings.Index(s, "-"); idx >= 0 {
+		core, v.Pre = s[:idx], s[idx+1:]
+	}
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return v, fmt.Errorf("invalid version %q", s)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return v, fmt.Errorf("invalid version %q", s)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	}
+	return comparePre(v.Pre, o.Pre)
+}
+
+func comparePre(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.Atoi(as[i])
+		bn, bErr := strconv.Atoi(bs[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		case as[i] != bs[i]:
+			if as[i] < bs[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+type comparator struct {
+	op string
+	v  Version
+}
+
+type Requirement []comparator
+
+func ParseRequirement(s string) (Requirement, error) {
+	var req Requirement
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" || part == "*" {
+			continue
+		}
+		op := ""
+		for _, candidate := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+			if strings.HasPrefix(part, candidate) {
+				op = candidate
+				break
+			}
+		}
+		raw := strings.TrimSpace(strings.TrimPrefix(part, op))
+		if op == "" {
+			op = "^"
+		}
+		cs, err := expand(op, raw)
+		if err != nil {
+			return nil, err
+		}
+		req = append(req, cs...)
+	}
+	return req, nil
+}
+
+func (r Requirement) Matches(v Version) bool {
+	for _, c := range r {
+		cmp := v.Compare(c.v)
+		ok := false
+		switch c.op {
+		case ">=":
+			ok = cmp >= 0
+		case ">":
+			ok = cmp > 0
+		case "<=":
+			ok = cmp <= 0
+		case "<":
+			ok = cmp < 0
+		case "=":
+			ok = cmp == 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func expand(op, raw string) ([]comparator, error) {
+	fields := strings.Split(strings.SplitN(raw, "-", 2)[0], ".")
+	pre := ""
+	if idx := strings.Index(raw, "-"); idx >= 0 {
+		pre = raw[idx+1:]
+	}
+	if len(fields) == 0 || len(fields) > 3 {
+		return nil, fmt.Errorf("invalid requirement %q", raw)
+	}
+	nums := make([]int, 0, 3)
+	for _, f := range fields {
+		if f == "*" || f == "x" {
+			break
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid requirement %q", raw)
+		}
+		nums = append(nums, n)
+	}
+	given := len(nums)
+	for len(nums) < 3 {
+		nums = append(nums, 0)
+	}
+	base := Version{Major: nums[0], Minor: nums[1], Patch: nums[2], Pre: pre}
+
+	switch op {
+	case ">=", "<", ">", "<=":
+		if given < 3 && (op == ">" || op == "<=") {
+			return []comparator{{op: map[string]string{">": ">=", "<=": "<"}[op], v: bump(base, given)}}, nil
+		}
+		return []comparator{{op: op, v: base}}, nil
+	case "=":
+		if given == 3 {
+			return []comparator{{op: "=", v: base}}, nil
+		}
+		return []comparator{{op: ">=", v: base}, {op: "<", v: bump(base, given)}}, nil
+	case "~":
+		upper := given
+		if upper > 2 {
+			upper = 2
+		}
+		return []comparator{{op: ">=", v: base}, {op: "<", v: bump(base, upper)}}, nil
+	case "^":
+		upper := 1
+		switch {
+		case base.Major == 0 && given == 1:
+			upper = 1
+		case base.Major == 0 && base.Minor == 0 && given == 2:
+			upper = 2
+		case base.Major == 0 && base.Minor == 0:
+			upper = 3
+		case base.Major == 0:
+			upper = 2
+		}
+		return []comparator{{op: ">=", v: base}, {op: "<", v: bump(base, upper)}}, nil
+	}
+	return nil, fmt.Errorf("unsupported operator %q", op)
+}
+
+func bump(v Version, significant int) Version {
+	switch significant {
+	case 0:
+		return Version{Major: 1 << 30}
+	case 1:
+		return Version{Major: v.Major + 1}
+	case 2:
+		return Version{Major: v.Major, Minor: v.Minor + 1}
+	}
+	return Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+}
@@ -0,0 +1,126 @@
+package audit
+
+import "testing"
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Version
+		wantErr bool
+	}{
+		{in: "1.2.3", want: Version{Major: 1, Minor: 2, Patch: 3}},
+		{in: " 0.10.0 ", want: Version{Minor: 10}},
+		{in: "1.0.0-alpha.1", want: Version{Major: 1, Pre: "alpha.1"}},
+		{in: "1.2.3+build.5", want: Version{Major: 1, Minor: 2, Patch: 3}},
+		{in: "1.2", wantErr: true},
+		{in: "1.x.3", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseVersion(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseVersion(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2.3", "1.2.4", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0", "1.0.0-rc.1", 1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-rc.1", "1.0.0-beta.11", 1},
+	}
+	for _, tt := range tests {
+		a, err := ParseVersion(tt.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ParseVersion(tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := a.Compare(b); got != tt.want {
+			t.Errorf("%s.Compare(%s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestRequirementMatches(t *testing.T) {
+	tests := []struct {
+		req     string
+		version string
+		want    bool
+	}{
+		{"^1.2.3", "1.2.3", true},
+		{"^1.2.3", "1.9.0", true},
+		{"^1.2.3", "2.0.0", false},
+		{"^1.2.3", "1.2.2", false},
+		{"1.2.3", "1.5.0", true},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.3", true},
+		{"^0.0.3", "0.0.4", false},
+		{"^0.0", "0.0.7", true},
+		{"^0.0", "0.1.0", false},
+		{"^0", "0.9.0", true},
+		{"^0", "1.0.0", false},
+		{"~1.2", "1.2.9", true},
+		{"~1.2", "1.3.0", false},
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"~1", "1.9.0", true},
+		{"~1", "2.0.0", false},
+		{"=1.2", "1.2.7", true},
+		{"=1.2", "1.3.0", false},
+		{"=1.2.3", "1.2.4", false},
+		{">1.2", "1.2.5", false},
+		{">1.2", "1.3.0", true},
+		{">1.2.3", "1.2.4", true},
+		{"<=1.2", "1.2.9", true},
+		{"<=1.2", "1.3.0", false},
+		{">= 1.0.0, < 1.5.0", "1.4.9", true},
+		{">= 1.0.0, < 1.5.0", "1.5.0", false},
+		{">= 1.0.0, < 1.5.0", "0.9.9", false},
+		{"1.*", "1.8.0", true},
+		{"1.*", "2.0.0", false},
+		{"*", "42.0.0", true},
+		{"", "0.0.1", true},
+		{">=1.0.0-alpha", "1.0.0-beta", true},
+		{"<1.0.0", "1.0.0-rc.1", true},
+	}
+	for _, tt := range tests {
+		req, err := ParseRequirement(tt.req)
+		if err != nil {
+			t.Errorf("ParseRequirement(%q) error = %v", tt.req, err)
+			continue
+		}
+		v, err := ParseVersion(tt.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := req.Matches(v); got != tt.want {
+			t.Errorf("%q matches %s = %v, want %v", tt.req, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestParseRequirementErrors(t *testing.T) {
+	for _, in := range []string{"^a.b.c", ">=1.2.3.4", "~1.x.y.z"} {
+		if _, err := ParseRequirement(in); err == nil {
+			t.Errorf("ParseRequirement(%q) succeeded, want error", in)
+		}
+	}
+}
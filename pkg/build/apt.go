@@ -0,0 +1,320 @@
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+type AptConfig struct {
+	Simulate    bool
+	LockTimeout time.Duration
+	MaxIndexAge time.Duration
+}
+
+var aptConfig = AptConfig{
+	LockTimeout: 10 * time.Minute,
+	MaxIndexAge: 24 * time.Hour,
+}
+
+func ConfigureApt(cfg AptConfig) {
+	aptConfig = cfg
+}
+
+func AptSimulating() bool {
+	return aptConfig.Simulate
+}
+
+var aptLockFiles = []string{
+	"/var/lib/dpkg/lock-frontend",
+	"/var/lib/dpkg/lock",
+	"/var/lib/apt/lists/lock",
+	"/var/cache/apt/archives/lock",
+}
+
+var aptEnv = []string{
+	"DEBIAN_FRONTEND=noninteractive",
+	"APT_LISTCHANGES_FRONTEND=none",
+	"NEEDRESTART_MODE=a",
+	"LC_ALL=C",
+}
+
+type AptError struct {
+	Op     string
+	Reason string
+	Hint   string
+	Err    error
+}
+
+func (e *AptError) Error() string {
+	msg := fmt.Sprintf("apt-get %s failed: %s", e.Op, e.Reason)
+	if e.Hint != "" {
+		msg += " (" + e.Hint + ")"
+	}
+	return msg
+}
+
+func (e *AptError) Unwrap() error {
+	return e.Err
+}
+
+type aptFailure struct {
+	pattern *regexp.Regexp
+	reason  string
+	hint    string
+}
+
+var aptFailures = []aptFailure{
+	{regexp.MustCompile(`Unable to locate package (\S+)`), "package %s is unknown to APT", "check the spelling, enable the component providing it (e.g. universe), or run with -static-deps"},
+	{regexp.MustCompile(`Package '?(\S+?)'? has no installation candidate`), "package %s has no installation candidate", "the package exists but is not installable from the configured sources; refresh indices or enable the matching suite"},
+	{regexp.MustCompile(`Could not get lock (\S+)`), "the lock %s is held by another process", "wait for unattended-upgrades or another package manager to finish, or raise -apt-lock-timeout"},
+	{regexp.MustCompile(`dpkg was interrupted`), "a previous dpkg run was interrupted", "run 'sudo dpkg --configure -a' and retry"},
+	{regexp.MustCompile(`Unmet dependencies|held broken packages`), "the requested packages have unmet dependencies", "run 'apt-get -s install' on the listed packages to inspect the conflict, or check for held packages with 'apt-mark showhold'"},
+	{regexp.MustCompile(`NO_PUBKEY (\S+)`), "a repository is signed with unknown key %s", "install the repository's keyring package or remove the source"},
+	{regexp.MustCompile(`Temporary failure resolving '([^']+)'`), "cannot resolve %s", "check network connectivity and DNS"},
+	{regexp.MustCompile(`Failed to fetch (\S+)`), "download of %s failed", "check the mirror and network connectivity, then retry"},
+	{regexp.MustCompile(`Hash Sum mismatch`), "downloaded index or package has a hash sum mismatch", "the mirror is syncing; retry later or switch mirrors"},
+	{regexp.MustCompile(`is not valid yet`), "a Release file is not valid yet", "the system clock is behind; synchronise it and retry"},
+	{regexp.MustCompile(`No space left on device`), "the disk is full", "free space under /var/cache/apt and the workdir"},
+}
+
+func parseAptFailure(op, output string, err error) *AptError {
+	for _, f := range aptFailures {
+		m := f.pattern.FindStringSubmatch(output)
+		if m == nil {
+			continue
+		}
+		reason := f.reason
+		if len(m) > 1 {
+			reason = fmt.Sprintf(f.reason, m[1])
+		}
+		return &AptError{Op: op, Reason: reason, Hint: f.hint, Err: err}
+	}
+	reason := err.Error()
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "E: ") {
+			reason = strings.TrimPrefix(line, "E: ")
+		}
+	}
+	return &AptError{Op: op, Reason: reason, Err: err}
+}
+
+func aptLockHolder(path string) (int, bool) {
+	if pid, held, err := procLockHolder(path); err == nil {
+		return pid, held
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	lk := syscall.Flock_t{Type: syscall.F_WRLCK, Whence: 0}
+	if err := syscall.FcntlFlock(f.Fd(), syscall.F_GETLK, &lk); err != nil {
+		return 0, false
+	}
+	if lk.Type == syscall.F_UNLCK {
+		return 0, false
+	}
+	return int(lk.Pid), true
+}
+
+func procLockHolder(path string) (int, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, false, nil
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false, fmt.Errorf("no inode information for %s", path)
+	}
+	data, err := os.ReadFile("/proc/locks")
+	if err != nil {
+		return 0, false, err
+	}
+	dev := uint64(st.Dev)
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 || fields[1] == "->" {
+			continue
+		}
+		var maj, min, ino uint64
+		if _, err := fmt.Sscanf(fields[5], "%x:%x:%d", &maj, &min, &ino); err != nil {
+			continue
+		}
+		if maj != major || min != minor || ino != uint64(st.Ino) {
+			continue
+		}
+		var pid int
+		fmt.Sscanf(fields[4], "%d", &pid)
+		return pid, true, nil
+	}
+	return 0, false, nil
+}
+
+func processName(pid int) string {
+	data, err := os.ReadFile(filepath.Join("/proc", fmt.Sprint(pid), "comm"))
+	if err != nil {
+		return "unknown"
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func waitForAptLock(logFn func(string, ...any)) error {
+	deadline := time.Now().Add(aptConfig.LockTimeout)
+	announced := false
+	for {
+		held := false
+		for _, path := range aptLockFiles {
+			pid, ok := aptLockHolder(path)
+			if !ok {
+				continue
+			}
+			held = true
+			if !announced {
+				logFn("Waiting up to %s for %s held by %s (pid %d)", aptConfig.LockTimeout, path, processName(pid), pid)
+				announced = true
+			}
+			break
+		}
+		if !held {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return &AptError{
+				Op:     "lock",
+				Reason: fmt.Sprintf("the dpkg lock is still held after %s", aptConfig.LockTimeout),
+				Hint:   "wait for unattended-upgrades or another package manager to finish, or raise -apt-lock-timeout",
+			}
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func runApt(op string, args []string, logFn func(string, ...any)) (string, error) {
+	if err := waitForAptLock(logFn); err != nil {
+		return "", err
+	}
+	timeout := int(aptConfig.LockTimeout.Seconds())
+	full := append([]string{
+		"-o", fmt.Sprintf("DPkg::Lock::Timeout=%d", timeout),
+		"-o", "Dpkg::Options::=--force-confdef",
+		"-o", "Dpkg::Options::=--force-confold",
+	}, args...)
+	var captured bytes.Buffer
+	cmdArgs := append(append([]string{}, aptEnv...), append([]string{"apt-get"}, full...)...)
+	cmd := exec.Command("env", cmdArgs...)
+	if !aptConfig.Simulate {
+		cmd = privilegedCommand("env", cmdArgs...)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = io.MultiWriter(os.Stdout, &captured)
+	cmd.Stderr = io.MultiWriter(os.Stderr, &captured)
+	if err := cmd.Run(); err != nil {
+		return captured.String(), parseAptFailure(op, captured.String(), err)
+	}
+	return captured.String(), nil
+}
+
+var aptUpdateStamps = []string{
+	"/var/lib/apt/periodic/update-success-stamp",
+	"/var/lib/apt/lists/partial",
+	"/var/lib/apt/lists",
+}
+
+func aptIndexAge() (time.Duration, bool) {
+	if lists, _ := filepath.Glob("/var/lib/apt/lists/*_Packages*"); len(lists) == 0 {
+		return 0, false
+	}
+	for _, stamp := range aptUpdateStamps {
+		if info, err := os.Stat(stamp); err == nil {
+			return time.Since(info.ModTime()), true
+		}
+	}
+	return 0, false
+}
+
+func RefreshAptIndices(force bool, logFn func(string, ...any)) error {
+	age, ok := aptIndexAge()
+	switch {
+	case force:
+		logFn("Refreshing APT package indices")
+	case !ok:
+		logFn("APT package indices are missing; running apt-get update")
+	case aptConfig.MaxIndexAge > 0 && age > aptConfig.MaxIndexAge:
+		logFn("APT package indices are %s old; running apt-get update", age.Round(time.Hour))
+	default:
+		return nil
+	}
+	if aptConfig.Simulate {
+		logFn("Simulation mode: skipping apt-get update")
+		return nil
+	}
+	_, err := runApt("update", []string{"update"}, logFn)
+	return err
+}
+
+func aptInstall(pkgs []string, logFn func(string, ...any)) error {
+	args := []string{"install", "-y", "--no-install-recommends"}
+	if aptConfig.Simulate {
+		args = append(args, "-s")
+	}
+	out, err := runApt("install", append(args, pkgs...), logFn)
+	if err != nil {
+		return err
+	}
+	if aptConfig.Simulate {
+		n := 0
+		for _, line := range strings.Split(out, "\n") {
+			if strings.HasPrefix(line, "Inst ") {
+				n++
+			}
+		}
+		logFn("Simulation mode: apt-get would install %d packages", n)
+	}
+	return nil
+}
+
+func InstallBuiltPackages(debs []string, logFn func(string, ...any)) error {
+	if len(debs) == 0 {
+		return fmt.Errorf("no .deb packages were built in this run")
+	}
+	var args []string
+	for _, d := range debs {
+		abs, err := filepath.Abs(d)
+		if err != nil {
+			return err
+		}
+		args = append(args, abs)
+	}
+	logFn("Installing %d packages built in this run", len(args))
+	if err := aptInstall(args, logFn); err != nil {
+		return err
+	}
+	if aptConfig.Simulate {
+		return nil
+	}
+	if _, err := exec.LookPath("systemctl"); err == nil {
+		if err := runPrivileged("systemctl", "daemon-reload"); err != nil {
+			logFn("WARNING: systemctl daemon-reload failed: %v", err)
+		}
+	}
+	return nil
+}
+
+func aptPurge(pkgs []string, logFn func(string, ...any)) error {
+	args := []string{"purge", "-y"}
+	if aptConfig.Simulate {
+		args = append(args, "-s")
+	}
+	_, err := runApt("purge", append(args, pkgs...), logFn)
+	return err
+}
@@ -0,0 +1,47 @@
+package build
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const BuildInfoSuffix = ".build-info.json"
+
+type BuildInfo struct {
+	Component    string   `json:"component"`
+	Version      string   `json:"version"`
+	Source       string   `json:"source"`
+	BuildSystem  string   `json:"build_system"`
+	CargoProfile string   `json:"cargo_profile,omitempty"`
+	Linker       string   `json:"linker,omitempty"`
+	Rustc        string   `json:"rustc,omitempty"`
+	Just         string   `json:"just,omitempty"`
+	Patches      []string `json:"patches,omitempty"`
+	Sccache      string   `json:"sccache,omitempty"`
+	BuiltAt      string   `json:"built_at"`
+}
+
+func NewBuildInfo(component, version, source string) *BuildInfo {
+	if source == "" {
+		source = "branch HEAD"
+	}
+	return &BuildInfo{
+		Component: component,
+		Version:   version,
+		Source:    source,
+		BuiltAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+func (b *BuildInfo) Write(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
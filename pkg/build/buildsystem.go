@@ -0,0 +1,338 @@
+package build
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jimed-rand/cosmic-deb/pkg/repos"
+)
+
+type BuildContext struct {
+	RepoDir  string
+	RepoName string
+	WorkDir  string
+	StageDir string
+	OutDir   string
+	Jobs     int
+	Env      []string
+	Output   io.Writer
+	Spec     repos.BuildSpec
+	Log      func(string, ...any)
+}
+
+func (c *BuildContext) recipe(kind, def string) string {
+	if r := c.Spec.Recipes; r != nil {
+		switch {
+		case kind == "vendor" && r.Vendor != "":
+			return r.Vendor
+		case kind == "build" && r.Build != "":
+			return r.Build
+		case kind == "install" && r.Install != "":
+			return r.Install
+		}
+	}
+	return def
+}
+
+func (c *BuildContext) cargoArgs() []string {
+	return CargoFeatureArgs(c.Spec)
+}
+
+func CargoFeatureArgs(spec repos.BuildSpec) []string {
+	var args []string
+	if spec.NoDefaultFeatures {
+		args = append(args, "--no-default-features")
+	}
+	if len(spec.Features) > 0 {
+		args = append(args, "--features="+strings.Join(spec.Features, ","))
+	}
+	return args
+}
+
+func (c *BuildContext) installVars(defaults ...string) []string {
+	return append(defaults, sortedAssignments(c.Spec.InstallVars)...)
+}
+
+func sortedAssignments(vars map[string]string) []string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]string, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, k+"="+vars[k])
+	}
+	return out
+}
+
+func (c *BuildContext) run(name string, args ...string) error {
+	return c.runIn(c.RepoDir, name, args...)
+}
+
+func (c *BuildContext) runIn(dir, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), c.Env...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if c.Output != nil {
+		cmd.Stdout = io.MultiWriter(os.Stdout, c.Output)
+		cmd.Stderr = io.MultiWriter(os.Stderr, c.Output)
+	}
+	return cmd.Run()
+}
+
+type BuildSystem interface {
+	Name() string
+	Detect(repoDir string) bool
+	Vendor(ctx *BuildContext) error
+	Compile(ctx *BuildContext) error
+	Install(ctx *BuildContext) error
+}
+
+var buildSystems []BuildSystem
+
+func RegisterBuildSystem(sys BuildSystem) {
+	for i, s := range buildSystems {
+		if s.Name() == sys.Name() {
+			buildSystems[i] = sys
+			return
+		}
+	}
+	buildSystems = append(buildSystems, sys)
+}
+
+func init() {
+	RegisterBuildSystem(debianSystem{})
+	RegisterBuildSystem(justSystem{})
+	RegisterBuildSystem(makeSystem{})
+	RegisterBuildSystem(mesonSystem{})
+	RegisterBuildSystem(cargoSystem{})
+}
+
+var systemBuildDeps = map[string][]string{
+	"meson": {"meson", "ninja-build"},
+}
+
+func SystemBuildDeps(name string) []string {
+	return systemBuildDeps[name]
+}
+
+func BuildSystemNames() []string {
+	var names []string
+	for _, s := range buildSystems {
+		names = append(names, s.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func LookupBuildSystem(name string) (BuildSystem, error) {
+	for _, s := range buildSystems {
+		if s.Name() == name {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown build system '%s' (known: %s)", name, strings.Join(BuildSystemNames(), ", "))
+}
+
+func DetectBuildSystem(repoDir string, exclude ...string) (BuildSystem, error) {
+	for _, s := range buildSystems {
+		skip := false
+		for _, e := range exclude {
+			if s.Name() == e {
+				skip = true
+			}
+		}
+		if !skip && s.Detect(repoDir) {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("No recognised build system in %s", repoDir)
+}
+
+func SelectBuildSystem(repoDir, name string) (BuildSystem, error) {
+	if name == "" || name == "auto" {
+		return DetectBuildSystem(repoDir)
+	}
+	return LookupBuildSystem(name)
+}
+
+var compiledDebs []string
+
+func CompiledDebs() []string {
+	return compiledDebs
+}
+
+func ProducesPackages(sys BuildSystem) bool {
+	_, ok := sys.(debianSystem)
+	return ok
+}
+
+type justSystem struct{}
+
+func (justSystem) Name() string { return "just" }
+
+func (justSystem) Detect(repoDir string) bool {
+	ok, _ := hasJustfile(repoDir)
+	return ok
+}
+
+func (justSystem) Vendor(ctx *BuildContext) error {
+	recipe := ctx.recipe("vendor", "vendor")
+	ctx.Log("Running 'just %s' for %s", recipe, ctx.RepoName)
+	_ = ctx.run("just", recipe)
+	return nil
+}
+
+func (justSystem) Compile(ctx *BuildContext) error {
+	features := ctx.cargoArgs()
+	if recipe := ctx.recipe("build", ""); recipe != "" {
+		return ctx.run("just", append([]string{recipe}, features...)...)
+	}
+	if _, err := os.Stat(filepath.Join(ctx.RepoDir, "vendor.tar")); err == nil {
+		return ctx.run("just", append([]string{"build-vendored"}, features...)...)
+	}
+	if err := ctx.run("just", append([]string{"build-release", "--frozen"}, features...)...); err != nil {
+		if buf, ok := ctx.Output.(*tailBuffer); ok {
+			buf.Reset()
+		}
+		return ctx.run("just", append([]string{"build-release"}, features...)...)
+	}
+	return nil
+}
+
+func (justSystem) Install(ctx *BuildContext) error {
+	args := ctx.installVars("rootdir="+ctx.StageDir, "DESTDIR="+ctx.StageDir)
+	return ctx.run("just", append(args, ctx.recipe("install", "install"))...)
+}
+
+type makeSystem struct{}
+
+func (makeSystem) Name() string { return "make" }
+
+func (makeSystem) Detect(repoDir string) bool {
+	_, err := os.Stat(filepath.Join(repoDir, "Makefile"))
+	return err == nil
+}
+
+func (makeSystem) Vendor(ctx *BuildContext) error {
+	return nil
+}
+
+func (makeSystem) Compile(ctx *BuildContext) error {
+	args := []string{fmt.Sprintf("-j%d", ctx.Jobs), strings.Join(append([]string{"ARGS=--frozen --release"}, ctx.cargoArgs()...), " ")}
+	if recipe := ctx.recipe("build", ""); recipe != "" {
+		args = append(args, recipe)
+	}
+	return ctx.run("make", args...)
+}
+
+func (makeSystem) Install(ctx *BuildContext) error {
+	args := ctx.installVars("prefix=/usr", "libexecdir=/usr/lib", "DESTDIR="+ctx.StageDir)
+	return ctx.run("make", append(args, ctx.recipe("install", "install"))...)
+}
+
+type debianSystem struct{}
+
+func (debianSystem) Name() string { return "debian" }
+
+func (debianSystem) Detect(repoDir string) bool {
+	_, err := os.Stat(filepath.Join(repoDir, "debian", "control"))
+	return err == nil
+}
+
+func (debianSystem) Vendor(ctx *BuildContext) error {
+	if ok, _ := hasJustfile(ctx.RepoDir); ok {
+		return justSystem{}.Vendor(ctx)
+	}
+	return nil
+}
+
+func (debianSystem) Compile(ctx *BuildContext) error {
+	ctx.Log("Using debian/ directory for %s", ctx.RepoName)
+	args := []string{"-us", "-uc", "-b"}
+	if activeCross != nil {
+		args = append(args, "-a"+activeCross.DebArch, "-Pcross,nocheck")
+	}
+	if ctx.Spec.SkipTests {
+		ctx.Env = append(ctx.Env, "DEB_BUILD_OPTIONS=nodbg nocheck", "DEB_BUILD_PROFILES=nocheck")
+	} else {
+		ctx.Env = append(ctx.Env, "DEB_BUILD_OPTIONS=nodbg")
+	}
+	parent := filepath.Dir(ctx.RepoDir)
+	before := changesSnapshot(parent)
+	if err := ctx.run("dpkg-buildpackage", args...); err != nil {
+		return err
+	}
+	debs, err := changedDebs(parent, before)
+	if err != nil {
+		return err
+	}
+	if len(debs) == 0 {
+		return fmt.Errorf("dpkg-buildpackage produced no .changes file listing binary packages in %s", parent)
+	}
+	for _, name := range debs {
+		dest := filepath.Join(ctx.OutDir, name)
+		if err := os.Rename(filepath.Join(parent, name), dest); err != nil {
+			ctx.Log("Warning: Failed to move .deb to output directory: %v", err)
+			continue
+		}
+		compiledDebs = append(compiledDebs, dest)
+	}
+	return nil
+}
+
+func changesSnapshot(dir string) map[string]time.Time {
+	snap := make(map[string]time.Time)
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.changes"))
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil {
+			snap[m] = info.ModTime()
+		}
+	}
+	return snap
+}
+
+func changedDebs(dir string, before map[string]time.Time) ([]string, error) {
+	var debs []string
+	for path, mtime := range changesSnapshot(dir) {
+		if prev, ok := before[path]; ok && !mtime.After(prev) {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		debs = append(debs, changesDebs(string(data))...)
+	}
+	sort.Strings(debs)
+	return debs, nil
+}
+
+func changesDebs(changes string) []string {
+	var debs []string
+	inFiles := false
+	for _, line := range strings.Split(changes, "\n") {
+		if !strings.HasPrefix(line, " ") {
+			inFiles = strings.HasPrefix(line, "Files:")
+			continue
+		}
+		fields := strings.Fields(line)
+		if inFiles && len(fields) == 5 && (strings.HasSuffix(fields[4], ".deb") || strings.HasSuffix(fields[4], ".udeb")) {
+			debs = append(debs, fields[4])
+		}
+	}
+	return debs
+}
+
+func (debianSystem) Install(ctx *BuildContext) error {
+	return nil
+}
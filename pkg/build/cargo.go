@@ -0,0 +1,129 @@
+package build
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var cargoDataDirs = []string{"res", "data", "resources", "assets"}
+
+type cargoSystem struct{}
+
+func (cargoSystem) Name() string { return "cargo" }
+
+func (cargoSystem) Detect(repoDir string) bool {
+	_, err := os.Stat(filepath.Join(repoDir, "Cargo.toml"))
+	return err == nil
+}
+
+func (cargoSystem) Vendor(ctx *BuildContext) error {
+	if _, err := os.Stat(filepath.Join(ctx.RepoDir, "Cargo.lock")); err != nil {
+		ctx.Log("No Cargo.lock in %s; resolving dependencies before fetching", ctx.RepoName)
+		return ctx.run("cargo", "fetch")
+	}
+	return ctx.run("cargo", "fetch", "--locked")
+}
+
+func (cargoSystem) Compile(ctx *BuildContext) error {
+	args := append([]string{"build", "--release", "--frozen", fmt.Sprintf("--jobs=%d", ctx.Jobs)}, ctx.cargoArgs()...)
+	return ctx.run("cargo", args...)
+}
+
+func (cargoSystem) Install(ctx *BuildContext) error {
+	bins, err := stageCargoBinaries(ctx.RepoDir, ctx.StageDir)
+	if err != nil {
+		return err
+	}
+	if bins == 0 {
+		return fmt.Errorf("no executables found in %s", filepath.Join(ctx.RepoDir, "target", "release"))
+	}
+	ctx.Log("Staged %d executable(s) for %s", bins, ctx.RepoName)
+	for _, dir := range cargoDataDirs {
+		root := filepath.Join(ctx.RepoDir, dir)
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			continue
+		}
+		n, err := stageCargoData(root, ctx.StageDir)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			ctx.Log("Staged %d data file(s) from %s/ for %s", n, dir, ctx.RepoName)
+		}
+	}
+	return nil
+}
+
+func stageCargoBinaries(repoDir, stageDir string) (int, error) {
+	release := filepath.Join(repoDir, "target", "release")
+	entries, err := os.ReadDir(release)
+	if err != nil {
+		return 0, err
+	}
+	binDir := filepath.Join(stageDir, "usr", "bin")
+	n := 0
+	for _, e := range entries {
+		if e.IsDir() || strings.Contains(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || info.Mode()&0111 == 0 {
+			continue
+		}
+		if err := os.MkdirAll(binDir, 0755); err != nil {
+			return n, err
+		}
+		if err := copyFile(filepath.Join(release, e.Name()), filepath.Join(binDir, e.Name()), 0755); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func cargoDataDest(root, path string) string {
+	rel, _ := filepath.Rel(root, path)
+	name := filepath.Base(path)
+	switch {
+	case strings.HasSuffix(name, ".desktop"):
+		return filepath.Join("usr", "share", "applications", name)
+	case strings.HasSuffix(name, ".metainfo.xml"), strings.HasSuffix(name, ".appdata.xml"):
+		return filepath.Join("usr", "share", "metainfo", name)
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i, p := range parts {
+		if p == "hicolor" && i+1 < len(parts) {
+			return filepath.Join(append([]string{"usr", "share", "icons", "hicolor"}, parts[i+1:]...)...)
+		}
+	}
+	if parts[0] == "icons" && len(parts) == 2 && filepath.Ext(name) == ".svg" {
+		return filepath.Join("usr", "share", "icons", "hicolor", "scalable", "apps", name)
+	}
+	return ""
+}
+
+func stageCargoData(root, stageDir string) (int, error) {
+	n := 0
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		dest := cargoDataDest(root, path)
+		if dest == "" {
+			return nil
+		}
+		target := filepath.Join(stageDir, dest)
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := copyFile(path, target, 0644); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
@@ -3,21 +3,29 @@ package build
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-)
+	"strings"
 
-const rustLinkerFlags = "-C link-arg=-fuse-ld=lld"
+	"github.com/jimed-rand/cosmic-deb/pkg/repos"
+)
 
-func buildEnv() []string {
-	existing := os.Getenv("RUSTFLAGS")
-	var rfVal string
-	if existing != "" {
-		rfVal = existing + " " + rustLinkerFlags
-	} else {
-		rfVal = rustLinkerFlags
+func buildEnv(profile repos.CargoProfile) []string {
+	var flags []string
+	if existing := os.Getenv("RUSTFLAGS"); existing != "" {
+		flags = append(flags, existing)
+	}
+	if lf := linkerRustFlags(); lf != "" {
+		flags = append(flags, lf)
+	}
+	if extra := profileRustFlags(profile); extra != "" {
+		flags = append(flags, extra)
 	}
-	return []string{"RUSTFLAGS=" + rfVal}
+	var env []string
+	if len(flags) > 0 {
+		env = append(env, "RUSTFLAGS="+strings.Join(flags, " "))
+	}
+	env = append(env, linkerEnv()...)
+	return append(env, cargoProfileEnv(profile)...)
 }
 
 func hasJustfile(dir string) (bool, string) {
@@ -32,56 +40,75 @@ func hasJustfile(dir string) (bool, string) {
 	return false, ""
 }
 
-func RunVendor(repoDir, workDir string, logFn func(string, ...any)) {
-	ApplyIsolatedRustEnv(workDir)
-	if ok, _ := hasJustfile(repoDir); ok {
-		logFn("Running 'just vendor' for %s", filepath.Base(repoDir))
-		cmd := exec.Command("just", "vendor")
-		cmd.Dir = repoDir
-		cmd.Env = os.Environ()
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		_ = cmd.Run()
+func newBuildContext(spec *repos.BuildSpec, repoDir, repoName, workDir string, jobs int, logFn func(string, ...any)) *BuildContext {
+	ctx := &BuildContext{
+		RepoDir:  repoDir,
+		RepoName: repoName,
+		WorkDir:  workDir,
+		Jobs:     jobs,
+		Log:      logFn,
+	}
+	if spec != nil {
+		ctx.Spec = *spec
 	}
+	return ctx
 }
 
-func Compile(repoDir, repoName, workDir string, jobs int, logFn func(string, ...any)) error {
-	ApplyIsolatedRustEnv(workDir)
-	logFn("Compiling component: %s", repoName)
-	env := buildEnv()
-
-	cargoToml := filepath.Join(repoDir, "Cargo.toml")
-	if _, err := os.Stat(cargoToml); err == nil {
-		sedCmd := exec.Command("sed", "-i", `s/lto = "fat"/lto = "thin"/`, "Cargo.toml")
-		sedCmd.Dir = repoDir
-		_ = sedCmd.Run()
-	}
+func (c *BuildContext) withEnv(base []string) {
+	c.Env = append(base, toolchainEnv(&c.Spec, c.RepoDir)...)
+	c.Env = append(c.Env, sortedAssignments(c.Spec.Env)...)
+}
 
-	hasJust, _ := hasJustfile(repoDir)
-	makefile := filepath.Join(repoDir, "Makefile")
+func RunVendor(sys BuildSystem, spec *repos.BuildSpec, repoDir, workDir string, logFn func(string, ...any)) error {
+	ApplyIsolatedRustEnv(workDir)
+	ctx := newBuildContext(spec, repoDir, filepath.Base(repoDir), workDir, 1, logFn)
+	ctx.withEnv(nil)
+	return sys.Vendor(ctx)
+}
 
-	if hasJust {
-		vendorTar := filepath.Join(repoDir, "vendor.tar")
-		if _, err := os.Stat(vendorTar); err == nil {
-			return runWithEnv(repoDir, env, "just", "build-vendored")
+func Compile(sys BuildSystem, spec *repos.BuildSpec, repoDir, repoName, workDir, outDir string, jobs int, logFn func(string, ...any)) error {
+	ApplyIsolatedRustEnv(workDir)
+	out := &tailBuffer{}
+	ctx := newBuildContext(spec, repoDir, repoName, workDir, jobs, logFn)
+	ctx.OutDir = outDir
+	ctx.Output = out
+	profile := ComponentCargoProfile(spec, repoDir)
+	packaged := ProducesPackages(sys)
+	targetDir := ""
+	if packaged {
+		ctx.withEnv(cargoProfileEnv(profile))
+	} else {
+		logFn("Compiling component: %s (%s, cargo profile %s)", repoName, sys.Name(), DescribeCargoProfile(profile))
+		env := buildEnv(profile)
+		if _, err := os.Stat(filepath.Join(repoDir, "Cargo.toml")); err == nil && sys.Name() != "meson" {
+			if targetDir = sharedTargetDir(repoDir, workDir); targetDir != "" {
+				logFn("Using shared Cargo target directory %s for %s", targetDir, repoName)
+				env = append(env, "CARGO_TARGET_DIR="+targetDir)
+			}
 		}
-		if err := runWithEnv(repoDir, env, "just", "build-release", "--frozen"); err != nil {
-			return runWithEnv(repoDir, env, "just", "build-release")
+		ctx.withEnv(env)
+	}
+	compiledDebs = nil
+	sccacheZeroStats()
+	err := sys.Compile(ctx)
+	sccacheRecordStats(repoName)
+	if err != nil {
+		output := out.String()
+		return &CompileError{Component: repoName, Err: err, Output: output, Diagnosis: Diagnose(output)}
+	}
+	if targetDir != "" {
+		n, err := publishSharedArtifacts(repoDir, targetDir)
+		if err != nil {
+			return fmt.Errorf("cannot publish artifacts of %s from %s: %v", repoName, targetDir, err)
 		}
+		logFn("Published %d artifact(s) of %s to target/release", n, repoName)
 		return nil
 	}
-	if _, err := os.Stat(makefile); err == nil {
-		return runWithEnv(repoDir, env, "make",
-			fmt.Sprintf("-j%d", jobs),
-			"ARGS=--frozen --release",
-		)
-	}
-	if _, err := os.Stat(cargoToml); err == nil {
-		return runWithEnv(repoDir, env, "cargo", "build", "--release", "--frozen",
-			fmt.Sprintf("--jobs=%d", jobs),
-		)
+	if activeCross != nil && !packaged {
+		logFn("Publishing %s artifacts of %s to target/release", activeCross.RustTriple, repoName)
+		return publishCrossArtifacts(repoDir, *activeCross)
 	}
-	return fmt.Errorf("No recognised build system in %s", repoDir)
+	return nil
 }
 
 func ValidateBuildOutput(repoDir string) bool {
@@ -104,54 +131,10 @@ func ValidateBuildOutput(repoDir string) bool {
 	return false
 }
 
-func InstallToStage(repoDir, stageDir, workDir string) error {
-	ApplyIsolatedRustEnv(workDir)
-	hasJust, _ := hasJustfile(repoDir)
-	makefile := filepath.Join(repoDir, "Makefile")
-
-	if hasJust {
-		return runCmd(repoDir, "just", "rootdir="+stageDir, "DESTDIR="+stageDir, "install")
-	}
-	if _, err := os.Stat(makefile); err == nil {
-		return runCmd(repoDir, "make",
-			"prefix=/usr",
-			"libexecdir=/usr/lib",
-			"DESTDIR="+stageDir,
-			"install",
-		)
-	}
-	return fmt.Errorf("No install target found in %s", repoDir)
-}
-
-func BuildWithDebianDir(repoDir, outDir, workDir string, logFn func(string, ...any)) error {
+func InstallToStage(sys BuildSystem, spec *repos.BuildSpec, repoDir, stageDir, workDir string, logFn func(string, ...any)) error {
 	ApplyIsolatedRustEnv(workDir)
-	logFn("Using debian/ directory for %s", filepath.Base(repoDir))
-	RunVendor(repoDir, workDir, logFn)
-	if err := runWithEnv(repoDir, []string{"DEB_BUILD_OPTIONS=nodbg"}, "dpkg-buildpackage", "-us", "-uc", "-b"); err != nil {
-		return err
-	}
-	parent := filepath.Dir(repoDir)
-	files, err := os.ReadDir(parent)
-	if err != nil {
-		return err
-	}
-	for _, f := range files {
-		if len(f.Name()) > 4 && f.Name()[len(f.Name())-4:] == ".deb" {
-			oldPath := filepath.Join(parent, f.Name())
-			newPath := filepath.Join(outDir, f.Name())
-			if err := os.Rename(oldPath, newPath); err != nil {
-				logFn("Warning: Failed to move .deb to output directory: %v", err)
-			}
-		}
-	}
-	return nil
-}
-
-func runWithEnv(dir string, extraEnv []string, name string, args ...string) error {
-	cmd := exec.Command(name, args...)
-	cmd.Dir = dir
-	cmd.Env = append(os.Environ(), extraEnv...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+	ctx := newBuildContext(spec, repoDir, filepath.Base(repoDir), workDir, 1, logFn)
+	ctx.StageDir = stageDir
+	ctx.withEnv(buildEnv(ComponentCargoProfile(spec, repoDir)))
+	return sys.Install(ctx)
 }
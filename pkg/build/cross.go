@@ -0,0 +1,147 @@
+package build
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type CrossTarget struct {
+	DebArch    string
+	RustTriple string
+	GNUTriple  string
+}
+
+var crossTargets = map[string]CrossTarget{
+	"amd64":   {DebArch: "amd64", RustTriple: "x86_64-unknown-linux-gnu", GNUTriple: "x86_64-linux-gnu"},
+	"arm64":   {DebArch: "arm64", RustTriple: "aarch64-unknown-linux-gnu", GNUTriple: "aarch64-linux-gnu"},
+	"armhf":   {DebArch: "armhf", RustTriple: "armv7-unknown-linux-gnueabihf", GNUTriple: "arm-linux-gnueabihf"},
+	"riscv64": {DebArch: "riscv64", RustTriple: "riscv64gc-unknown-linux-gnu", GNUTriple: "riscv64-linux-gnu"},
+	"ppc64el": {DebArch: "ppc64el", RustTriple: "powerpc64le-unknown-linux-gnu", GNUTriple: "powerpc64le-linux-gnu"},
+}
+
+var activeCross *CrossTarget
+
+func CrossTargetFor(debArch string) (CrossTarget, error) {
+	if t, ok := crossTargets[debArch]; ok {
+		return t, nil
+	}
+	var known []string
+	for arch := range crossTargets {
+		known = append(known, arch)
+	}
+	sort.Strings(known)
+	return CrossTarget{}, fmt.Errorf("no cross-compilation target known for architecture '%s' (supported: %s)", debArch, strings.Join(known, ", "))
+}
+
+func (t CrossTarget) envTriple() string {
+	return strings.ReplaceAll(t.RustTriple, "-", "_")
+}
+
+func (t CrossTarget) Env() []string {
+	upper := strings.ToUpper(t.envTriple())
+	gcc := t.GNUTriple + "-gcc"
+	return []string{
+		"CARGO_BUILD_TARGET=" + t.RustTriple,
+		"CARGO_TARGET_" + upper + "_LINKER=" + gcc,
+		"CC_" + t.envTriple() + "=" + gcc,
+		"CXX_" + t.envTriple() + "=" + t.GNUTriple + "-g++",
+		"AR_" + t.envTriple() + "=" + t.GNUTriple + "-ar",
+		"PKG_CONFIG_ALLOW_CROSS=1",
+		"PKG_CONFIG_SYSROOT_DIR=/",
+		"PKG_CONFIG_LIBDIR=" + filepath.Join("/usr/lib", t.GNUTriple, "pkgconfig") + ":/usr/share/pkgconfig",
+		"DEB_HOST_ARCH=" + t.DebArch,
+		"DEB_HOST_GNU_TYPE=" + t.GNUTriple,
+		"DEB_HOST_MULTIARCH=" + t.GNUTriple,
+	}
+}
+
+func ApplyCrossEnv(t CrossTarget) {
+	for _, kv := range t.Env() {
+		k, v, _ := strings.Cut(kv, "=")
+		os.Setenv(k, v)
+	}
+	activeCross = &t
+}
+
+func CheckCrossToolchain(t CrossTarget) error {
+	for _, tool := range []string{t.GNUTriple + "-gcc", t.GNUTriple + "-g++"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			return fmt.Errorf("cross compiler '%s' not found in PATH (install crossbuild-essential-%s)", tool, t.DebArch)
+		}
+	}
+	return nil
+}
+
+func EnableForeignArch(debArch string, logFn func(string, ...any)) error {
+	out, err := exec.Command("dpkg", "--print-foreign-architectures").Output()
+	if err != nil {
+		return err
+	}
+	for _, a := range strings.Fields(string(out)) {
+		if a == debArch {
+			return nil
+		}
+	}
+	if aptConfig.Simulate {
+		logFn("Simulation mode: would enable dpkg foreign architecture %s", debArch)
+		return nil
+	}
+	logFn("Enabling dpkg foreign architecture: %s", debArch)
+	if err := runPrivileged("dpkg", "--add-architecture", debArch); err != nil {
+		return err
+	}
+	return RefreshAptIndices(true, logFn)
+}
+
+func publishCrossArtifacts(repoDir string, t CrossTarget) error {
+	srcDir := filepath.Join(repoDir, "target", t.RustTriple, "release")
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return nil
+	}
+	dstDir := filepath.Join(repoDir, "target", "release")
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || info.Mode()&0111 == 0 && !strings.HasSuffix(e.Name(), ".so") {
+			continue
+		}
+		src := filepath.Join(srcDir, e.Name())
+		dst := filepath.Join(dstDir, e.Name())
+		_ = os.Remove(dst)
+		if err := os.Link(src, dst); err == nil {
+			continue
+		}
+		if err := copyFile(src, dst, info.Mode()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
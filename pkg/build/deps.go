@@ -9,7 +9,7 @@ import (
 
 func CargoBinDir(workDir string) string {
 	if workDir != "" {
-		return filepath.Join(workDir, ".cargo", "bin")
+		return filepath.Join(rustEnvRoot(workDir), ".cargo", "bin")
 	}
 	cargoHome := os.Getenv("CARGO_HOME")
 	if cargoHome == "" {
@@ -31,18 +31,18 @@ func EnsureCargoBinInPath(workDir string) {
 }
 
 func ApplyIsolatedRustEnv(workDir string) {
-	absWorkDir, err := filepath.Abs(workDir)
-	if err != nil {
-		absWorkDir = workDir
-	}
-	cargoHome := filepath.Join(absWorkDir, ".cargo")
-	rustupHome := filepath.Join(absWorkDir, ".rustup")
-	os.Setenv("CARGO_HOME", cargoHome)
-	os.Setenv("RUSTUP_HOME", rustupHome)
-	EnsureCargoBinInPath(absWorkDir)
+	root := rustEnvRoot(workDir)
+	os.Setenv("CARGO_HOME", filepath.Join(root, ".cargo"))
+	os.Setenv("RUSTUP_HOME", filepath.Join(root, ".rustup"))
+	EnsureCargoBinInPath(workDir)
+	applySccacheEnv()
 }
 
 func PurgeIsolatedRustEnv(workDir string, logFn func(string, ...any)) {
+	if PersistentToolchain() {
+		logFn("Keeping persistent Rust environment in %s", persistentRustDir)
+		return
+	}
 	logFn("Purging isolated Rust environment in %s", workDir)
 	os.RemoveAll(filepath.Join(workDir, ".cargo"))
 	os.RemoveAll(filepath.Join(workDir, ".rustup"))
@@ -59,43 +59,52 @@ func CheckPackagesInstalled(pkgs []string) (missing []string) {
 	return missing
 }
 
-func InstallPackages(pkgs []string, logFn func(string, ...any)) error {
-	args := append([]string{"install", "-y", "--no-install-recommends"}, pkgs...)
-	executable := "apt-get"
-	execArgs := args
-	if os.Geteuid() != 0 {
-		executable = "sudo"
-		execArgs = append([]string{"apt-get"}, args...)
+func InstallPackages(workDir string, pkgs []string, logFn func(string, ...any)) error {
+	if aptConfig.Simulate {
+		return aptInstall(pkgs, logFn)
+	}
+	before, err := InstalledSnapshot()
+	if err != nil {
+		return err
+	}
+	if err := aptInstall(pkgs, logFn); err != nil {
+		return err
 	}
-	return runCmd("", executable, execArgs...)
+	return recordInstalled(workDir, before, logFn)
 }
 
-func EnsureRustToolchain(workDir string, logFn func(string, ...any)) error {
+func EnsureRustToolchain(workDir string, targets []string, logFn func(string, ...any)) error {
 	ApplyIsolatedRustEnv(workDir)
 	if _, err := exec.LookPath("rustup"); err != nil {
-		logFn("The rustup binary was not found in PATH; Installing via sh.rustup.rs")
-		if err := runCmd("", "sh", "-c", "curl --proto '=https' --tlsv1.2 -sSf https://sh.rustup.rs | sh -s -- -y --no-modify-path"); err != nil {
+		logFn("The rustup binary was not found in PATH; installing rustup %s", rustSetup.RustupVersion)
+		if err := installRustup(workDir, logFn); err != nil {
 			return err
 		}
 		EnsureCargoBinInPath(workDir)
+	} else if PersistentToolchain() {
+		logFn("Reusing persistent Rust toolchain in %s (%s)", persistentRustDir, rustcVersion())
+		if err := checkToolchainUpdates(logFn); err != nil {
+			return err
+		}
 	}
-	logFn("Configuring Rust stable toolchain via rustup")
-	if err := runCmd("", "rustup", "default", "stable"); err != nil {
-		return err
-	}
-	EnsureCargoBinInPath(workDir)
-	return nil
-}
-
-func EnsureJust(workDir string, logFn func(string, ...any)) error {
-	EnsureCargoBinInPath(workDir)
-	if _, err := exec.LookPath("just"); err != nil {
-		logFn("The 'just' binary was not found in PATH; installing via cargo")
-		if err := runCmd("", "cargo", "install", "just"); err != nil {
+	name := rustSetup.Toolchain
+	if rustSetup.Archive != "" {
+		var err error
+		if name, err = installToolchainArchive(workDir, logFn); err != nil {
 			return err
 		}
-		EnsureCargoBinInPath(workDir)
 	}
+	if err := installToolchain(name, nil, targets, logFn); err != nil {
+		return err
+	}
+	logFn("Configuring Rust %s toolchain via rustup", name)
+	if err := runCmd("", "rustup", "default", name); err != nil {
+		return err
+	}
+	activeToolchain = name
+	rustTargets = targets
+	EnsureCargoBinInPath(workDir)
+	logFn("Default Rust toolchain: %s", rustcVersion())
 	return nil
 }
 
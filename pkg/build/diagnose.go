@@ -0,0 +1,191 @@
+package build
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/jimed-rand/cosmic-deb/pkg/distro"
+)
+
+const outputTailLimit = 512 * 1024
+
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > outputTailLimit {
+		t.buf = t.buf[len(t.buf)-outputTailLimit:]
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.buf)
+}
+
+type Diagnosis struct {
+	Kind     string
+	Summary  string
+	Hints    []string
+	Packages []string
+}
+
+type CompileError struct {
+	Component string
+	Err       error
+	Output    string
+	Diagnosis *Diagnosis
+}
+
+func (e *CompileError) Error() string {
+	if e.Diagnosis != nil {
+		return fmt.Sprintf("%v (%s)", e.Err, e.Diagnosis.Summary)
+	}
+	return e.Err.Error()
+}
+
+func (e *CompileError) Unwrap() error {
+	return e.Err
+}
+
+func (d *Diagnosis) Log(component string, logFn func(string, ...any)) {
+	logFn("Diagnosis for %s: %s", component, d.Summary)
+	for _, h := range d.Hints {
+		logFn("  hint: %s", h)
+	}
+	if len(d.Packages) > 0 {
+		logFn("  packages: %s", strings.Join(d.Packages, " "))
+	}
+}
+
+var (
+	reSysLibrary   = regexp.MustCompile("The system library `([^`]+)` required by crate `([^`]+)` was not found")
+	rePkgConfig    = regexp.MustCompile(`Package '?([\w.+-]+)'?,? (?:required by '[^']*', )?was not found in the pkg-config search path`)
+	reHeader       = regexp.MustCompile(`fatal error: ([\w./+-]+\.h): No such file or directory`)
+	reLibClang     = regexp.MustCompile(`Unable to find libclang|couldn't find any valid shared libraries matching: \['libclang`)
+	reLinkLib      = regexp.MustCompile(`(?:cannot find|unable to find library) -l([\w.+-]+)`)
+	reLinkerCmd    = regexp.MustCompile("linker `([^`]+)` not found")
+	reFuseLd       = regexp.MustCompile(`invalid linker name in argument '-fuse-ld=(\w+)'`)
+	reOOM          = regexp.MustCompile(`signal: 9, SIGKILL|memory allocation of \d+ bytes failed|LLVM ERROR: out of memory|Cannot allocate memory`)
+	reFrozen       = regexp.MustCompile(`--frozen was specified|--offline was specified|--locked was passed|attempting to make an HTTP request, but --frozen`)
+	reNetwork      = regexp.MustCompile(`failed to download|Couldn't resolve host name|failed to fetch|spurious network error`)
+	reRustcVersion = regexp.MustCompile(`requires rustc ([\d.]+) or newer, while the currently active rustc version is ([\d.]+)`)
+	reEdition      = regexp.MustCompile("feature `(edition20\\d\\d)` is required")
+	reDiskFull     = regexp.MustCompile(`No space left on device`)
+)
+
+func Diagnose(output string) *Diagnosis {
+	if m := reSysLibrary.FindStringSubmatch(output); m != nil {
+		return missingDependency("pkg-config", m[1], fmt.Sprintf("system library '%s' required by crate %s was not found", m[1], m[2]))
+	}
+	if m := rePkgConfig.FindStringSubmatch(output); m != nil {
+		return missingDependency("pkg-config", m[1], fmt.Sprintf("pkg-config module '%s' was not found", m[1]))
+	}
+	if m := reHeader.FindStringSubmatch(output); m != nil {
+		return missingDependency("header", m[1], fmt.Sprintf("C header '%s' was not found", m[1]))
+	}
+	if reLibClang.MatchString(output) {
+		return missingDependency("library", "libclang", "bindgen could not locate libclang")
+	}
+	if m := reFuseLd.FindStringSubmatch(output); m != nil {
+		return &Diagnosis{
+			Kind:     "linker",
+			Summary:  fmt.Sprintf("the C compiler does not support the '%s' linker", m[1]),
+			Hints:    []string{"install the linker package, or choose another one with -linker (lld, mold or system)"},
+			Packages: []string{m[1]},
+		}
+	}
+	if m := reLinkerCmd.FindStringSubmatch(output); m != nil {
+		d := &Diagnosis{
+			Kind:    "linker",
+			Summary: fmt.Sprintf("linker '%s' was not found", m[1]),
+			Hints:   []string{"install a C toolchain providing the linker"},
+		}
+		switch {
+		case m[1] == "cc" || m[1] == "gcc":
+			d.Packages = []string{"build-essential"}
+		case activeCross != nil && strings.HasPrefix(m[1], activeCross.GNUTriple):
+			d.Packages = []string{"crossbuild-essential-" + activeCross.DebArch}
+		}
+		return d
+	}
+	if m := reLinkLib.FindStringSubmatch(output); m != nil {
+		return missingDependency("library", m[1], fmt.Sprintf("the linker cannot find library '-l%s'", m[1]))
+	}
+	if reOOM.MatchString(output) {
+		return &Diagnosis{
+			Kind:    "oom",
+			Summary: "rustc was killed or ran out of memory",
+			Hints: []string{
+				"lower -jobs (for example -jobs 2) to reduce peak memory",
+				"add swap space or build on a machine with more RAM",
+				"build with -cargo-profile lowmem (no LTO, more codegen units, no debuginfo)",
+			},
+		}
+	}
+	if m := reRustcVersion.FindStringSubmatch(output); m != nil {
+		return &Diagnosis{
+			Kind:    "toolchain",
+			Summary: fmt.Sprintf("a dependency requires rustc %s but %s is active", m[1], m[2]),
+			Hints:   []string{"run 'rustup update stable' in the isolated environment, or rebuild without -skip-deps so the toolchain is refreshed"},
+		}
+	}
+	if m := reEdition.FindStringSubmatch(output); m != nil {
+		return &Diagnosis{
+			Kind:    "toolchain",
+			Summary: fmt.Sprintf("the active cargo does not support %s", m[1]),
+			Hints:   []string{"update the Rust toolchain with 'rustup update stable'"},
+		}
+	}
+	if reFrozen.MatchString(output) {
+		return &Diagnosis{
+			Kind:    "offline",
+			Summary: "cargo needed network access or a lock file update while running with --frozen",
+			Hints: []string{
+				"vendor the dependencies first ('just vendor') so the build can run offline",
+				"check that Cargo.lock matches the tagged sources, or build from a tag rather than branch HEAD",
+			},
+		}
+	}
+	if reNetwork.MatchString(output) {
+		return &Diagnosis{
+			Kind:    "network",
+			Summary: "downloading crates failed",
+			Hints:   []string{"check network connectivity and proxy settings, then retry"},
+		}
+	}
+	if reDiskFull.MatchString(output) {
+		return &Diagnosis{
+			Kind:    "disk",
+			Summary: "the disk is full",
+			Hints:   []string{"free space in the workdir or point -workdir at a larger filesystem"},
+		}
+	}
+	return nil
+}
+
+func missingDependency(kind, name, summary string) *Diagnosis {
+	d := &Diagnosis{Kind: "missing-dependency", Summary: summary}
+	if pkg := distro.ProviderFor(kind, name); pkg != "" {
+		d.Packages = []string{pkg}
+		d.Hints = []string{fmt.Sprintf("install %s", pkg)}
+	} else {
+		d.Hints = []string{fmt.Sprintf("install the development package providing %s '%s' (apt-file search can locate it)", kind, name)}
+	}
+	return d
+}
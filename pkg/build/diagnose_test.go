@@ -0,0 +1,157 @@
+package build
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiagnose(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		kind     string
+		summary  string
+		packages []string
+	}{
+		{
+			name:     "system library",
+			output:   "error: failed to run custom build command\n  The system library `xkbcommon` required by crate `smithay-client-toolkit` was not found.",
+			kind:     "missing-dependency",
+			summary:  "system library 'xkbcommon' required by crate smithay-client-toolkit was not found",
+			packages: []string{"libxkbcommon-dev"},
+		},
+		{
+			name:     "pkg-config module",
+			output:   "Package freetype2 was not found in the pkg-config search path.\nPerhaps you should add the directory containing `freetype2.pc'",
+			kind:     "missing-dependency",
+			summary:  "pkg-config module 'freetype2' was not found",
+			packages: []string{"libfreetype-dev"},
+		},
+		{
+			name:    "pkg-config module required by another",
+			output:  "Package 'no-such-module', required by 'virtual:world', was not found in the pkg-config search path",
+			kind:    "missing-dependency",
+			summary: "pkg-config module 'no-such-module' was not found",
+		},
+		{
+			name:     "header",
+			output:   "src/pam.c:1:10: fatal error: security/pam_appl.h: No such file or directory",
+			kind:     "missing-dependency",
+			summary:  "C header 'security/pam_appl.h' was not found",
+			packages: []string{"libpam0g-dev"},
+		},
+		{
+			name:     "libclang",
+			output:   "thread 'main' panicked at 'Unable to find libclang: \"couldn't find any valid shared libraries\"'",
+			kind:     "missing-dependency",
+			summary:  "bindgen could not locate libclang",
+			packages: []string{"libclang-dev"},
+		},
+		{
+			name:     "fuse-ld",
+			output:   "cc: error: invalid linker name in argument '-fuse-ld=mold'",
+			kind:     "linker",
+			summary:  "the C compiler does not support the 'mold' linker",
+			packages: []string{"mold"},
+		},
+		{
+			name:     "missing cc",
+			output:   "error: linker `cc` not found\n  |\n  = note: No such file or directory (os error 2)",
+			kind:     "linker",
+			summary:  "linker 'cc' was not found",
+			packages: []string{"build-essential"},
+		},
+		{
+			name:    "link library",
+			output:  "/usr/bin/ld: cannot find -lnosuchlib: No such file or directory",
+			kind:    "missing-dependency",
+			summary: "the linker cannot find library '-lnosuchlib'",
+		},
+		{
+			name:    "oom",
+			output:  "error: could not compile `cosmic-comp` (bin \"cosmic-comp\")\nCaused by:\n  process didn't exit successfully (signal: 9, SIGKILL: kill)",
+			kind:    "oom",
+			summary: "rustc was killed or ran out of memory",
+		},
+		{
+			name:    "rustc version",
+			output:  "error: package `foo v1.0.0` cannot be built because it requires rustc 1.85 or newer, while the currently active rustc version is 1.80.1",
+			kind:    "toolchain",
+			summary: "a dependency requires rustc 1.85 but 1.80.1 is active",
+		},
+		{
+			name:    "edition",
+			output:  "feature `edition2024` is required\n\nThe package requires the Cargo feature called `edition2024`",
+			kind:    "toolchain",
+			summary: "the active cargo does not support edition2024",
+		},
+		{
+			name:    "frozen",
+			output:  "error: failed to get `serde` as a dependency of package `foo`\nCaused by:\n  attempting to make an HTTP request, but --frozen was specified",
+			kind:    "offline",
+			summary: "cargo needed network access or a lock file update while running with --frozen",
+		},
+		{
+			name:    "network",
+			output:  "warning: spurious network error (3 tries remaining): [6] Couldn't resolve host name",
+			kind:    "network",
+			summary: "downloading crates failed",
+		},
+		{
+			name:    "disk full",
+			output:  "error: failed to write target/release/deps/libfoo.rlib: No space left on device (os error 28)",
+			kind:    "disk",
+			summary: "the disk is full",
+		},
+	}
+	for _, tt := range tests {
+		d := Diagnose(tt.output)
+		if d == nil {
+			t.Errorf("%s: Diagnose returned nil", tt.name)
+			continue
+		}
+		if d.Kind != tt.kind || d.Summary != tt.summary {
+			t.Errorf("%s: Diagnose = %s %q, want %s %q", tt.name, d.Kind, d.Summary, tt.kind, tt.summary)
+		}
+		if !reflect.DeepEqual(d.Packages, tt.packages) {
+			t.Errorf("%s: Packages = %v, want %v", tt.name, d.Packages, tt.packages)
+		}
+		if len(d.Hints) == 0 {
+			t.Errorf("%s: no hints", tt.name)
+		}
+	}
+}
+
+func TestDiagnoseUnknown(t *testing.T) {
+	for _, output := range []string{"", "error[E0308]: mismatched types", "warning: unused variable: `x`"} {
+		if d := Diagnose(output); d != nil {
+			t.Errorf("Diagnose(%q) = %+v, want nil", output, d)
+		}
+	}
+}
+
+func TestDiagnoseCrossLinker(t *testing.T) {
+	saved := activeCross
+	defer func() { activeCross = saved }()
+	cross := crossTargets["arm64"]
+	activeCross = &cross
+
+	d := Diagnose("error: linker `aarch64-linux-gnu-gcc` not found")
+	if d == nil || !reflect.DeepEqual(d.Packages, []string{"crossbuild-essential-arm64"}) {
+		t.Errorf("Diagnose = %+v, want crossbuild-essential-arm64", d)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	var tb tailBuffer
+	tb.Write([]byte("head"))
+	tb.Write([]byte(strings.Repeat("x", outputTailLimit)))
+	if got := tb.String(); len(got) != outputTailLimit || strings.HasPrefix(got, "head") {
+		t.Errorf("tail buffer kept %d bytes starting %q, want the last %d bytes", len(got), got[:4], outputTailLimit)
+	}
+	tb.Reset()
+	if tb.String() != "" {
+		t.Error("Reset did not clear the buffer")
+	}
+}
@@ -0,0 +1,164 @@
+package build
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/jimed-rand/cosmic-deb/pkg/distro"
+)
+
+const DefaultJustVersion = "1.40.0"
+
+type JustSetup struct {
+	Version string
+	Archive string
+	SHA256  string
+}
+
+var (
+	justSetup   = JustSetup{Version: DefaultJustVersion}
+	justVersion string
+	justSource  string
+)
+
+func ConfigureJust(s JustSetup) error {
+	if s.Version == "" {
+		s.Version = DefaultJustVersion
+	}
+	if s.Archive != "" {
+		if _, err := os.Stat(s.Archive); err != nil {
+			return err
+		}
+	}
+	justSetup = s
+	return nil
+}
+
+func JustVersion() string {
+	if justVersion == "" {
+		justVersion = installedJustVersion("just")
+	}
+	return justVersion
+}
+
+func installedJustVersion(bin string) string {
+	out, err := exec.Command(bin, "--version").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(out)), "just"))
+}
+
+const aptJustBinary = "/usr/bin/just"
+
+func justFromAPT(workDir, distroID, codename string, logFn func(string, ...any)) bool {
+	if !distro.HasJustInApt(distroID, codename) {
+		return false
+	}
+	if len(CheckPackagesInstalled([]string{"just"})) > 0 {
+		logFn("Installing 'just' from the distribution archive")
+		if err := InstallPackages(workDir, []string{"just"}, logFn); err != nil {
+			logFn("WARNING: Cannot install the 'just' package: %v", err)
+			return false
+		}
+	}
+	if _, err := os.Stat(aptJustBinary); err != nil {
+		return false
+	}
+	stale := filepath.Join(CargoBinDir(workDir), "just")
+	if _, err := os.Stat(stale); err == nil {
+		logFn("Removing just %s from %s so it does not shadow %s", installedJustVersion(stale), CargoBinDir(workDir), aptJustBinary)
+		if err := os.Remove(stale); err != nil {
+			logFn("WARNING: Cannot remove %s: %v", stale, err)
+			return false
+		}
+	}
+	return true
+}
+
+func justFromArchive(workDir string, logFn func(string, ...any)) error {
+	archive := justSetup.Archive
+	expected := justSetup.SHA256
+	if expected == "" {
+		data, err := os.ReadFile(archive + ".sha256")
+		if err != nil {
+			return fmt.Errorf("no checksum for %s; pass -just-sha256 or provide %s.sha256", filepath.Base(archive), filepath.Base(archive))
+		}
+		fields := strings.Fields(string(data))
+		if len(fields) == 0 {
+			return fmt.Errorf("empty checksum file %s.sha256", archive)
+		}
+		expected = fields[0]
+	}
+	sum, err := verifySHA256(archive, expected)
+	if err != nil {
+		return err
+	}
+	logFn("Verified %s (sha256 %s)", filepath.Base(archive), sum)
+	tmp, err := os.MkdirTemp(workDir, "just-archive-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if out, err := exec.Command("tar", "-xf", archive, "-C", tmp).CombinedOutput(); err != nil {
+		return fmt.Errorf("extracting %s: %s", archive, strings.TrimSpace(string(out)))
+	}
+	var bin string
+	_ = filepath.WalkDir(tmp, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && d.Name() == "just" && bin == "" {
+			bin = path
+		}
+		return nil
+	})
+	if bin == "" {
+		return fmt.Errorf("%s contains no 'just' binary", archive)
+	}
+	binDir := CargoBinDir(workDir)
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		return err
+	}
+	return copyFile(bin, filepath.Join(binDir, "just"), 0755)
+}
+
+func EnsureJust(workDir, distroID, codename string, logFn func(string, ...any)) error {
+	EnsureCargoBinInPath(workDir)
+	bin := filepath.Join(CargoBinDir(workDir), "just")
+	if installedJustVersion(bin) == justSetup.Version {
+		justSource = "isolated environment"
+	} else if justFromAPT(workDir, distroID, codename, logFn) {
+		bin = aptJustBinary
+		justSource = "distribution package"
+	} else if justSetup.Archive != "" {
+		logFn("Installing 'just' from %s", justSetup.Archive)
+		if err := justFromArchive(workDir, logFn); err != nil {
+			return err
+		}
+		justSource = "prebuilt archive"
+	} else if path, err := exec.LookPath("just"); err == nil {
+		bin = path
+		justSource = "PATH"
+	} else {
+		logFn("The 'just' binary was not found; installing just %s via cargo", justSetup.Version)
+		if err := runCmd("", "cargo", "install", "--locked", "--version", justSetup.Version, "just"); err != nil {
+			return err
+		}
+		justSource = "cargo install"
+	}
+	EnsureCargoBinInPath(workDir)
+	justVersion = installedJustVersion(bin)
+	if justVersion == "" {
+		return fmt.Errorf("'just' is not runnable after installation from %s", justSource)
+	}
+	if path, err := exec.LookPath("just"); err == nil && path != bin {
+		logFn("WARNING: %s shadows %s in PATH", path, bin)
+	}
+	if (justSource == "prebuilt archive" || justSource == "distribution package") && justVersion != justSetup.Version {
+		logFn("WARNING: The %s provides just %s, expected %s (-just-version)", justSource, justVersion, justSetup.Version)
+	}
+	logFn("Using just %s (%s, %s)", justVersion, justSource, bin)
+	return nil
+}
@@ -0,0 +1,117 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var LinkerStrategies = []string{"auto", "mold", "lld", "system"}
+
+var linkerPackages = map[string]string{
+	"mold": "mold",
+	"lld":  "lld",
+}
+
+var linkerBinaries = map[string]string{
+	"mold": "mold",
+	"lld":  "ld.lld",
+}
+
+var activeLinker = "system"
+
+func LinkerPackage(strategy string) string {
+	return linkerPackages[strategy]
+}
+
+func ActiveLinker() string {
+	return activeLinker
+}
+
+func linkerDriver() string {
+	if activeCross != nil {
+		return activeCross.GNUTriple + "-gcc"
+	}
+	if cc := os.Getenv("CC"); cc != "" {
+		return cc
+	}
+	return "cc"
+}
+
+func probeLinker(name, workDir string) error {
+	if _, err := exec.LookPath(linkerBinaries[name]); err != nil {
+		return fmt.Errorf("%s not found in PATH (install %s)", linkerBinaries[name], linkerPackages[name])
+	}
+	driver := linkerDriver()
+	dir, err := os.MkdirTemp(workDir, "linker-probe-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "probe.c")
+	if err := os.WriteFile(src, []byte("int main(void) { return 0; }\n"), 0644); err != nil {
+		return err
+	}
+	args := strings.Fields(driver)
+	args = append(args, "-fuse-ld="+name, src, "-o", filepath.Join(dir, "probe"))
+	out, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s cannot link with -fuse-ld=%s: %s", driver, name, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func CheckLinkerStrategy(strategy string) error {
+	for _, s := range LinkerStrategies {
+		if s == strategy {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown linker strategy '%s' (known: %s)", strategy, strings.Join(LinkerStrategies, ", "))
+}
+
+func ConfigureLinker(strategy, workDir string, logFn func(string, ...any)) (string, error) {
+	if err := CheckLinkerStrategy(strategy); err != nil {
+		return "", err
+	}
+	switch strategy {
+	case "system":
+		activeLinker = "system"
+	case "mold", "lld":
+		if err := probeLinker(strategy, workDir); err != nil {
+			return "", fmt.Errorf("linker '%s' unavailable: %v", strategy, err)
+		}
+		activeLinker = strategy
+	case "auto":
+		activeLinker = "system"
+		for _, name := range []string{"mold", "lld"} {
+			if err := probeLinker(name, workDir); err != nil {
+				logFn("Linker %s not usable: %v", name, err)
+				continue
+			}
+			activeLinker = name
+			break
+		}
+	}
+	return activeLinker, nil
+}
+
+func linkerRustFlags() string {
+	if activeLinker == "system" {
+		return ""
+	}
+	return "-C link-arg=-fuse-ld=" + activeLinker
+}
+
+func linkerEnv() []string {
+	if activeLinker == "system" {
+		return nil
+	}
+	flag := "-fuse-ld=" + activeLinker
+	if existing := os.Getenv("LDFLAGS"); existing != "" {
+		flag = existing + " " + flag
+	}
+	return []string{"LDFLAGS=" + flag}
+}
@@ -0,0 +1,178 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const DepsManifestName = "installed-deps.json"
+
+type DepsManifest struct {
+	UpdatedAt string   `json:"updated_at"`
+	Packages  []string `json:"packages"`
+}
+
+func DepsManifestPath(workDir string) string {
+	return filepath.Join(workDir, DepsManifestName)
+}
+
+func LoadDepsManifest(workDir string) (*DepsManifest, error) {
+	data, err := os.ReadFile(DepsManifestPath(workDir))
+	if os.IsNotExist(err) {
+		return &DepsManifest{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m DepsManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %v", DepsManifestPath(workDir), err)
+	}
+	return &m, nil
+}
+
+func (m *DepsManifest) Save(workDir string) error {
+	sort.Strings(m.Packages)
+	m.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(DepsManifestPath(workDir), append(data, '\n'), 0644)
+}
+
+func (m *DepsManifest) Add(pkgs []string) {
+	seen := make(map[string]bool, len(m.Packages))
+	for _, p := range m.Packages {
+		seen[p] = true
+	}
+	for _, p := range pkgs {
+		if !seen[p] {
+			seen[p] = true
+			m.Packages = append(m.Packages, p)
+		}
+	}
+}
+
+func InstalledSnapshot() (map[string]bool, error) {
+	out, err := exec.Command("dpkg-query", "-W", "-f=${binary:Package}\t${db:Status-Abbrev}\n").Output()
+	if err != nil {
+		return nil, fmt.Errorf("dpkg-query failed: %v", err)
+	}
+	installed := make(map[string]bool)
+	for _, line := range strings.Split(string(out), "\n") {
+		name, status, ok := strings.Cut(line, "\t")
+		if ok && strings.HasPrefix(status, "ii") {
+			installed[name] = true
+		}
+	}
+	return installed, nil
+}
+
+func recordInstalled(workDir string, before map[string]bool, logFn func(string, ...any)) error {
+	after, err := InstalledSnapshot()
+	if err != nil {
+		return err
+	}
+	var added []string
+	for name := range after {
+		if !before[name] {
+			added = append(added, name)
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	sort.Strings(added)
+	if err := runPrivileged("apt-mark", append([]string{"auto"}, added...)...); err != nil {
+		logFn("WARNING: apt-mark auto failed: %v", err)
+	}
+	m, err := LoadDepsManifest(workDir)
+	if err != nil {
+		return err
+	}
+	m.Add(added)
+	if err := m.Save(workDir); err != nil {
+		return err
+	}
+	logFn("Recorded %d newly installed packages in %s", len(added), DepsManifestPath(workDir))
+	return nil
+}
+
+func PurgeInstalledDeps(workDir string, logFn func(string, ...any)) error {
+	m, err := LoadDepsManifest(workDir)
+	if err != nil {
+		return err
+	}
+	if len(m.Packages) == 0 {
+		logFn("No build dependencies recorded in %s; nothing to purge", DepsManifestPath(workDir))
+		return nil
+	}
+	installed, err := InstalledSnapshot()
+	if err != nil {
+		return err
+	}
+	removable, err := autoremovable()
+	if err != nil {
+		return err
+	}
+	var purge, kept []string
+	for _, p := range m.Packages {
+		if !installed[p] {
+			continue
+		}
+		if removable[baseName(p)] {
+			purge = append(purge, p)
+		} else {
+			kept = append(kept, p)
+		}
+	}
+	if len(kept) > 0 {
+		logFn("Keeping %d recorded packages still required by installed software: %s", len(kept), strings.Join(kept, ", "))
+	}
+	if len(purge) > 0 {
+		logFn("Purging %d build dependencies installed by cosmic-deb", len(purge))
+		if err := aptPurge(purge, logFn); err != nil {
+			return err
+		}
+	}
+	if aptConfig.Simulate {
+		return nil
+	}
+	if len(kept) > 0 {
+		m.Packages = kept
+		return m.Save(workDir)
+	}
+	if err := os.Remove(DepsManifestPath(workDir)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func autoremovable() (map[string]bool, error) {
+	cmd := exec.Command("apt-get", "-s", "autoremove", "--purge")
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("apt-get autoremove simulation failed: %v", err)
+	}
+	removable := make(map[string]bool)
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && (fields[0] == "Purg" || fields[0] == "Remv") {
+			removable[baseName(fields[1])] = true
+		}
+	}
+	return removable, nil
+}
+
+func baseName(pkg string) string {
+	name, _, _ := strings.Cut(pkg, ":")
+	return name
+}
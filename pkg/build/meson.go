@@ -0,0 +1,47 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const mesonBuildDir = "build"
+
+type mesonSystem struct{}
+
+func (mesonSystem) Name() string { return "meson" }
+
+func (mesonSystem) Detect(repoDir string) bool {
+	_, err := os.Stat(filepath.Join(repoDir, "meson.build"))
+	return err == nil
+}
+
+func (mesonSystem) Vendor(ctx *BuildContext) error {
+	if _, err := os.Stat(filepath.Join(ctx.RepoDir, "subprojects")); err == nil {
+		ctx.Log("Downloading meson subprojects for %s", ctx.RepoName)
+		return ctx.run("meson", "subprojects", "download")
+	}
+	return nil
+}
+
+func (mesonSystem) Compile(ctx *BuildContext) error {
+	if _, err := os.Stat(filepath.Join(ctx.RepoDir, mesonBuildDir, "build.ninja")); err != nil {
+		args := []string{"setup", mesonBuildDir, "--prefix=/usr", "--libexecdir=lib", "--buildtype=release"}
+		if activeCross != nil {
+			crossFile := filepath.Join(ctx.WorkDir, "meson-cross-"+activeCross.DebArch+".ini")
+			if err := ctx.run("meson", "env2mfile", "--cross", "--debarch", activeCross.DebArch, "-o", crossFile); err != nil {
+				return fmt.Errorf("cannot generate meson cross file for %s: %v", activeCross.DebArch, err)
+			}
+			args = append(args, "--cross-file", crossFile)
+		}
+		if err := ctx.run("meson", args...); err != nil {
+			return err
+		}
+	}
+	return ctx.run("meson", "compile", "-C", mesonBuildDir, fmt.Sprintf("-j%d", ctx.Jobs))
+}
+
+func (mesonSystem) Install(ctx *BuildContext) error {
+	return ctx.run("meson", "install", "-C", mesonBuildDir, "--destdir", ctx.StageDir, "--no-rebuild")
+}
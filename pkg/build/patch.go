@@ -0,0 +1,184 @@
+package build
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Patch struct {
+	Name  string
+	Path  string
+	Strip int
+}
+
+type HunkFailure struct {
+	File string
+	Hunk int
+	Line int
+}
+
+func (h HunkFailure) String() string {
+	if h.Hunk == 0 {
+		return fmt.Sprintf("%s: file not found", h.File)
+	}
+	return fmt.Sprintf("%s: hunk #%d failed at line %d", h.File, h.Hunk, h.Line)
+}
+
+type PatchResult struct {
+	Patch    Patch
+	Applied  bool
+	Skipped  bool
+	Failures []HunkFailure
+	Output   string
+}
+
+var (
+	rePatchingFile = regexp.MustCompile(`^(?:checking|patching) file '?([^']+?)'?$`)
+	reHunkFailed   = regexp.MustCompile(`^Hunk #(\d+) FAILED at (\d+)`)
+	reMissingFile  = regexp.MustCompile(`can't find file to patch|No file to patch`)
+	reReversed     = regexp.MustCompile(`Reversed \(or previously applied\) patch detected`)
+)
+
+func PatchQueue(patchesDir, component string) ([]Patch, error) {
+	dir := filepath.Join(patchesDir, component)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, nil
+	}
+	series := filepath.Join(dir, "series")
+	if data, err := os.ReadFile(series); err == nil {
+		return parseSeries(dir, data)
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "*.patch"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	var queue []Patch
+	for _, m := range matches {
+		queue = append(queue, Patch{Name: filepath.Base(m), Path: m, Strip: 1})
+	}
+	return queue, nil
+}
+
+func parseSeries(dir string, data []byte) ([]Patch, error) {
+	var queue []Patch
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := sc.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		p := Patch{Name: fields[0], Path: filepath.Join(dir, fields[0]), Strip: 1}
+		for _, opt := range fields[1:] {
+			if !strings.HasPrefix(opt, "-p") {
+				return nil, fmt.Errorf("series line %d: unsupported option '%s'", lineNo, opt)
+			}
+			n, err := strconv.Atoi(strings.TrimPrefix(opt, "-p"))
+			if err != nil {
+				return nil, fmt.Errorf("series line %d: invalid strip level '%s'", lineNo, opt)
+			}
+			p.Strip = n
+		}
+		if _, err := os.Stat(p.Path); err != nil {
+			return nil, fmt.Errorf("series line %d: %v", lineNo, err)
+		}
+		queue = append(queue, p)
+	}
+	return queue, sc.Err()
+}
+
+func runPatch(repoDir string, p Patch, dryRun bool) (string, error) {
+	args := []string{fmt.Sprintf("-p%d", p.Strip), "--forward", "--batch", "--no-backup-if-mismatch", "-r", "-", "-i", p.Path}
+	if dryRun {
+		args = append(args, "--dry-run")
+	}
+	cmd := exec.Command("patch", args...)
+	cmd.Dir = repoDir
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func hunkFailures(output string) []HunkFailure {
+	var failures []HunkFailure
+	file := ""
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if m := rePatchingFile.FindStringSubmatch(line); m != nil {
+			file = m[1]
+			continue
+		}
+		if m := reHunkFailed.FindStringSubmatch(line); m != nil {
+			hunk, _ := strconv.Atoi(m[1])
+			at, _ := strconv.Atoi(m[2])
+			failures = append(failures, HunkFailure{File: file, Hunk: hunk, Line: at})
+			continue
+		}
+		if reMissingFile.MatchString(line) {
+			failures = append(failures, HunkFailure{File: "(unknown)"})
+		}
+	}
+	return failures
+}
+
+func ApplyPatches(repoDir string, queue []Patch, checkOnly bool, logFn func(string, ...any)) ([]PatchResult, error) {
+	var results []PatchResult
+	failed := 0
+	for _, p := range queue {
+		res := PatchResult{Patch: p}
+		out, err := runPatch(repoDir, p, true)
+		res.Output = out
+		switch {
+		case err == nil:
+			if _, err := runPatch(repoDir, p, false); err != nil {
+				return results, fmt.Errorf("applying %s: %v", p.Name, err)
+			}
+			res.Applied = true
+			logFn("Patch %s applied", p.Name)
+		case reReversed.MatchString(out) && len(hunkFailures(out)) == 0:
+			res.Skipped = true
+			logFn("Patch %s already applied; skipping", p.Name)
+		default:
+			failed++
+			res.Failures = hunkFailures(out)
+			logFn("Patch %s does not apply:", p.Name)
+			for _, f := range res.Failures {
+				logFn("  %s", f)
+			}
+			if len(res.Failures) == 0 {
+				logFn("  %s", strings.TrimSpace(out))
+			}
+		}
+		results = append(results, res)
+		if failed > 0 && !checkOnly {
+			return results, fmt.Errorf("patch %s failed to apply", p.Name)
+		}
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d patch(es) failed to apply", failed, len(queue))
+	}
+	return results, nil
+}
+
+func AppliedPatches(results []PatchResult) []string {
+	var names []string
+	for _, r := range results {
+		if r.Applied || r.Skipped {
+			names = append(names, r.Patch.Name)
+		}
+	}
+	return names
+}
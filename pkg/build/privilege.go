@@ -0,0 +1,143 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+type Escalator struct {
+	Name           string
+	Command        []string
+	NonInteractive []string
+	Validate       []string
+}
+
+var knownEscalators = []Escalator{
+	{Name: "sudo", Command: []string{"sudo"}, NonInteractive: []string{"-n"}, Validate: []string{"-v"}},
+	{Name: "doas", Command: []string{"doas"}, NonInteractive: []string{"-n"}},
+	{Name: "run0", Command: []string{"run0"}, NonInteractive: []string{"--no-ask-password"}},
+	{Name: "pkexec", Command: []string{"pkexec"}},
+}
+
+var (
+	escalator         *Escalator
+	escalatorResolved bool
+	privInteractive   = true
+)
+
+func ConfigureEscalator(helper string) error {
+	escalatorResolved = true
+	escalator = nil
+	if os.Geteuid() == 0 {
+		return nil
+	}
+	if helper == "" || helper == "auto" {
+		for _, e := range knownEscalators {
+			if _, err := exec.LookPath(e.Command[0]); err == nil {
+				e := e
+				escalator = &e
+				return nil
+			}
+		}
+		return fmt.Errorf("not running as root and none of sudo, doas, run0 or pkexec is installed")
+	}
+	fields := strings.Fields(helper)
+	if _, err := exec.LookPath(fields[0]); err != nil {
+		return fmt.Errorf("privilege helper '%s' not found in PATH", fields[0])
+	}
+	for _, e := range knownEscalators {
+		if e.Name == fields[0] {
+			e.Command = fields
+			escalator = &e
+			return nil
+		}
+	}
+	escalator = &Escalator{Name: fields[0], Command: fields}
+	return nil
+}
+
+func ActiveEscalator() *Escalator {
+	if !escalatorResolved {
+		ConfigureEscalator("")
+	}
+	return escalator
+}
+
+func SetPrivilegeInteractive(interactive bool) {
+	privInteractive = interactive
+}
+
+func StdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (e *Escalator) wrap(name string, args []string) []string {
+	cmd := append([]string{}, e.Command...)
+	if !privInteractive {
+		cmd = append(cmd, e.NonInteractive...)
+	}
+	return append(append(cmd, name), args...)
+}
+
+func privilegedCommand(name string, args ...string) *exec.Cmd {
+	if os.Geteuid() == 0 {
+		return exec.Command(name, args...)
+	}
+	e := ActiveEscalator()
+	if e == nil {
+		return exec.Command(name, args...)
+	}
+	full := e.wrap(name, args)
+	return exec.Command(full[0], full[1:]...)
+}
+
+func PreflightPrivileges(logFn func(string, ...any)) error {
+	if os.Geteuid() == 0 {
+		return nil
+	}
+	e := ActiveEscalator()
+	if e == nil {
+		return fmt.Errorf("not running as root and no privilege helper is available; install sudo, doas or run0, or pass -privilege-helper")
+	}
+	if len(e.NonInteractive) > 0 {
+		check := append(append(append([]string{}, e.Command[1:]...), e.NonInteractive...), "true")
+		if exec.Command(e.Command[0], check...).Run() == nil {
+			logFn("Privilege escalation via %s is available without a password prompt", e.Name)
+			return nil
+		}
+	}
+	if len(e.NonInteractive) == 0 && !privInteractive {
+		logFn("Privilege helper %s cannot be probed non-interactively; assuming it authenticates without a terminal", e.Name)
+		return nil
+	}
+	if !privInteractive {
+		return fmt.Errorf("%s requires interactive authentication but this run is non-interactive; run as root, configure passwordless rules for %s, or refresh credentials before starting", e.Name, e.Name)
+	}
+	logFn("Authenticating with %s for package management", e.Name)
+	validate := append([]string{}, e.Command[1:]...)
+	if len(e.Validate) > 0 {
+		validate = append(validate, e.Validate...)
+	} else {
+		validate = append(validate, "true")
+	}
+	cmd := exec.Command(e.Command[0], validate...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s authentication failed: %v", e.Name, err)
+	}
+	return nil
+}
+
+func runPrivileged(name string, args ...string) error {
+	cmd := privilegedCommand(name, args...)
+	cmd.Env = os.Environ()
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
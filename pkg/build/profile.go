@@ -0,0 +1,157 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jimed-rand/cosmic-deb/pkg/repos"
+)
+
+var cargoPresets = map[string]repos.CargoProfile{
+	"upstream": {},
+	"balanced": {},
+	"release":  {LTO: "fat", CodegenUnits: 1},
+	"lowmem":   {LTO: "off", CodegenUnits: 16, Debug: "0"},
+}
+
+var (
+	cargoProfile repos.CargoProfile
+	lowMemHost   bool
+)
+
+func ConfigureCargoProfile(spec string, lowEnd bool) (repos.CargoProfile, error) {
+	p, err := repos.ParseCargoProfile(spec)
+	if err != nil {
+		return p, err
+	}
+	lowMemHost = lowEnd
+	cargoProfile = resolveProfile(repos.CargoProfile{}, p)
+	return cargoProfile, nil
+}
+
+func resolveProfile(base, override repos.CargoProfile) repos.CargoProfile {
+	p := base
+	if override.Preset != "" {
+		p = presetProfile(override.Preset)
+	} else if p.Preset == "" {
+		p = presetProfile("auto")
+	}
+	if override.LTO != "" {
+		p.LTO = override.LTO
+	}
+	if override.CodegenUnits != 0 {
+		p.CodegenUnits = override.CodegenUnits
+	}
+	if override.OptLevel != "" {
+		p.OptLevel = override.OptLevel
+	}
+	if override.Debug != "" {
+		p.Debug = override.Debug
+	}
+	if override.Panic != "" {
+		p.Panic = override.Panic
+	}
+	if override.TargetCPU != "" {
+		p.TargetCPU = override.TargetCPU
+	}
+	return p
+}
+
+func presetProfile(name string) repos.CargoProfile {
+	if name == "auto" {
+		name = "balanced"
+		if lowMemHost {
+			name = "lowmem"
+		}
+	}
+	p := cargoPresets[name]
+	p.Preset = name
+	return p
+}
+
+func ComponentCargoProfile(spec *repos.BuildSpec, repoDir string) repos.CargoProfile {
+	p := cargoProfile
+	if spec != nil {
+		override := repos.CargoProfile{}
+		if spec.Profile != nil {
+			override = *spec.Profile
+		}
+		p = resolveProfile(cargoProfile, override)
+	}
+	if p.Preset == "balanced" && p.LTO == "" {
+		switch manifestReleaseLTO(repoDir) {
+		case "true", "fat":
+			p.LTO = "thin"
+		}
+	}
+	return p
+}
+
+func manifestReleaseLTO(repoDir string) string {
+	data, err := os.ReadFile(filepath.Join(repoDir, "Cargo.toml"))
+	if err != nil {
+		return ""
+	}
+	section := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			section = strings.Trim(line, "[] ")
+			continue
+		}
+		if section != "profile.release" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.TrimSpace(key) == "lto" {
+			value, _, _ = strings.Cut(value, "#")
+			return strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+	return ""
+}
+
+func DescribeCargoProfile(p repos.CargoProfile) string {
+	parts := []string{p.Preset}
+	add := func(key, value string) {
+		if value != "" {
+			parts = append(parts, key+"="+value)
+		}
+	}
+	add("lto", p.LTO)
+	if p.CodegenUnits > 0 {
+		add("codegen-units", strconv.Itoa(p.CodegenUnits))
+	}
+	add("opt-level", p.OptLevel)
+	add("debuginfo", p.Debug)
+	add("panic", p.Panic)
+	add("target-cpu", p.TargetCPU)
+	return strings.Join(parts, ",")
+}
+
+func cargoProfileEnv(p repos.CargoProfile) []string {
+	var env []string
+	set := func(key, value string) {
+		if value != "" {
+			env = append(env, "CARGO_PROFILE_RELEASE_"+key+"="+value)
+		}
+	}
+	set("LTO", p.LTO)
+	if p.CodegenUnits > 0 {
+		set("CODEGEN_UNITS", strconv.Itoa(p.CodegenUnits))
+	}
+	set("OPT_LEVEL", p.OptLevel)
+	set("DEBUG", p.Debug)
+	set("PANIC", p.Panic)
+	return env
+}
+
+func profileRustFlags(p repos.CargoProfile) string {
+	if p.TargetCPU == "" {
+		return ""
+	}
+	return fmt.Sprintf("-C target-cpu=%s", p.TargetCPU)
+}
@@ -0,0 +1,399 @@
+package build
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/jimed-rand/cosmic-deb/pkg/repos"
+)
+
+const DefaultRustupVersion = "1.28.2"
+
+const rustupArchiveURL = "https://static.rust-lang.org/rustup/archive/%s/%s/rustup-init"
+
+var rustupHostTriples = map[string]string{
+	"amd64":   "x86_64-unknown-linux-gnu",
+	"arm64":   "aarch64-unknown-linux-gnu",
+	"arm":     "armv7-unknown-linux-gnueabihf",
+	"riscv64": "riscv64gc-unknown-linux-gnu",
+	"ppc64le": "powerpc64le-unknown-linux-gnu",
+}
+
+type RustSetup struct {
+	Toolchain     string
+	RustupVersion string
+	RustupSHA256  string
+	RustupInit    string
+	Archive       string
+}
+
+const offlineToolchainPrefix = "cosmic-deb-"
+
+var (
+	rustSetup        = RustSetup{Toolchain: "stable", RustupVersion: DefaultRustupVersion}
+	activeToolchain  string
+	rustTargets      []string
+	offlineToolchain string
+)
+
+func ConfigureRustSetup(s RustSetup) error {
+	if s.Toolchain == "" {
+		s.Toolchain = "stable"
+	}
+	if err := repos.CheckToolchain(s.Toolchain); err != nil {
+		return err
+	}
+	if s.RustupVersion == "" {
+		s.RustupVersion = DefaultRustupVersion
+	}
+	if s.RustupSHA256 != "" {
+		s.RustupSHA256 = strings.ToLower(s.RustupSHA256)
+		if _, err := hex.DecodeString(s.RustupSHA256); err != nil || len(s.RustupSHA256) != 64 {
+			return fmt.Errorf("invalid rustup-init SHA-256 '%s'", s.RustupSHA256)
+		}
+	}
+	for _, path := range []string{s.RustupInit, s.Archive} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+	}
+	if s.Archive != "" {
+		if _, err := archiveToolchainName(s.Archive); err != nil {
+			return err
+		}
+	}
+	rustSetup = s
+	return nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func verifySHA256(path, expected string) (string, error) {
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return "", err
+	}
+	if sum != strings.ToLower(expected) {
+		return sum, fmt.Errorf("checksum mismatch for %s: expected %s, got %s", filepath.Base(path), expected, sum)
+	}
+	return sum, nil
+}
+
+func fetchRustupInit(dir string, logFn func(string, ...any)) (string, string, error) {
+	if rustSetup.RustupInit != "" {
+		return rustSetup.RustupInit, rustSetup.RustupSHA256, nil
+	}
+	triple, ok := rustupHostTriples[runtime.GOARCH]
+	if !ok {
+		return "", "", fmt.Errorf("no rustup-init build known for %s; pass -rustup-init", runtime.GOARCH)
+	}
+	url := fmt.Sprintf(rustupArchiveURL, rustSetup.RustupVersion, triple)
+	path := filepath.Join(dir, "rustup-init")
+	logFn("Downloading rustup-init %s for %s", rustSetup.RustupVersion, triple)
+	if out, err := exec.Command("curl", "--proto", "=https", "--tlsv1.2", "-fsSL", "-o", path, url).CombinedOutput(); err != nil {
+		return "", "", fmt.Errorf("downloading %s: %s", url, strings.TrimSpace(string(out)))
+	}
+	expected := rustSetup.RustupSHA256
+	if expected == "" {
+		out, err := exec.Command("curl", "--proto", "=https", "--tlsv1.2", "-fsSL", url+".sha256").Output()
+		if err != nil {
+			return "", "", fmt.Errorf("downloading %s.sha256: %v", url, err)
+		}
+		fields := strings.Fields(string(out))
+		if len(fields) == 0 {
+			return "", "", fmt.Errorf("empty checksum file %s.sha256", url)
+		}
+		expected = fields[0]
+	}
+	return path, expected, nil
+}
+
+func installRustup(workDir string, logFn func(string, ...any)) error {
+	dir, err := os.MkdirTemp(workDir, "rustup-init-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	path, expected, err := fetchRustupInit(dir, logFn)
+	if err != nil {
+		return err
+	}
+	if expected != "" {
+		sum, err := verifySHA256(path, expected)
+		if err != nil {
+			return err
+		}
+		logFn("Verified rustup-init (sha256 %s)", sum)
+	} else {
+		sum, _ := fileSHA256(path)
+		logFn("WARNING: Local rustup-init is not verified; pass -rustup-sha256 %s to pin it", sum)
+	}
+	if err := os.Chmod(path, 0755); err != nil {
+		return err
+	}
+	return runCmd("", path, "-y", "--no-modify-path", "--profile", "minimal", "--default-toolchain", "none")
+}
+
+func archiveToolchainName(archive string) (string, error) {
+	base := filepath.Base(archive)
+	for _, ext := range []string{".tar.xz", ".tar.gz", ".tar.zst", ".tar"} {
+		base = strings.TrimSuffix(base, ext)
+	}
+	version, _, ok := strings.Cut(strings.TrimPrefix(base, "rust-"), "-")
+	if !strings.HasPrefix(base, "rust-") || !ok || repos.CheckToolchain(version) != nil {
+		return "", fmt.Errorf("cannot tell the Rust version of '%s' (expected rust-<version>-<triple>.tar.xz)", filepath.Base(archive))
+	}
+	return version, nil
+}
+
+func localToolchainName(name string) string {
+	if offlineToolchain != "" && name == offlineToolchain {
+		return offlineToolchainPrefix + name
+	}
+	return name
+}
+
+func installToolchainArchive(workDir string, logFn func(string, ...any)) (string, error) {
+	archive := rustSetup.Archive
+	version, err := archiveToolchainName(archive)
+	if err != nil {
+		return "", err
+	}
+	name := offlineToolchainPrefix + version
+	if _, err := os.Stat(archive + ".sha256"); err == nil {
+		data, err := os.ReadFile(archive + ".sha256")
+		if err != nil {
+			return "", err
+		}
+		fields := strings.Fields(string(data))
+		if len(fields) == 0 {
+			return "", fmt.Errorf("empty checksum file %s.sha256", archive)
+		}
+		if _, err := verifySHA256(archive, fields[0]); err != nil {
+			return "", err
+		}
+		logFn("Verified %s against %s.sha256", filepath.Base(archive), filepath.Base(archive))
+	}
+	if toolchainInstalled(name) {
+		offlineToolchain = version
+		return name, nil
+	}
+	prefix := filepath.Join(rustEnvRoot(workDir), ".rustup", "offline", version)
+	tmp, err := os.MkdirTemp(workDir, "rust-archive-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	logFn("Installing Rust %s from %s", name, archive)
+	if out, err := exec.Command("tar", "-xf", archive, "-C", tmp).CombinedOutput(); err != nil {
+		return "", fmt.Errorf("extracting %s: %s", archive, strings.TrimSpace(string(out)))
+	}
+	scripts, _ := filepath.Glob(filepath.Join(tmp, "*", "install.sh"))
+	if len(scripts) == 0 {
+		return "", fmt.Errorf("%s contains no install.sh", archive)
+	}
+	if err := runCmd(filepath.Dir(scripts[0]), "sh", "install.sh", "--prefix="+prefix, "--disable-ldconfig"); err != nil {
+		return "", err
+	}
+	if err := runCmd("", "rustup", "toolchain", "link", name, prefix); err != nil {
+		return "", err
+	}
+	offlineToolchain = version
+	return name, nil
+}
+
+func toolchainInstalled(name string) bool {
+	out, err := exec.Command("rustup", "toolchain", "list").Output()
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && toolchainMatches(fields[0], name) {
+			return true
+		}
+	}
+	return false
+}
+
+func toolchainMatches(installed, name string) bool {
+	rest, ok := strings.CutPrefix(installed, name+"-")
+	return installed == name || ok && rest != "" && (rest[0] < '0' || rest[0] > '9')
+}
+
+func installToolchain(name string, components, targets []string, logFn func(string, ...any)) error {
+	if strings.HasPrefix(name, offlineToolchainPrefix) {
+		if !toolchainInstalled(name) {
+			return fmt.Errorf("offline toolchain %s is not linked", name)
+		}
+		if len(components)+len(targets) > 0 {
+			logFn("WARNING: Components and targets cannot be added to the offline toolchain %s; %s must be part of the archive", name, strings.Join(append(components, targets...), ", "))
+		}
+		return nil
+	}
+	if toolchainInstalled(name) {
+		for _, t := range targets {
+			if err := runCmd("", "rustup", "target", "add", "--toolchain", name, t); err != nil {
+				return err
+			}
+		}
+		for _, c := range components {
+			if err := runCmd("", "rustup", "component", "add", "--toolchain", name, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	logFn("Installing Rust toolchain %s via rustup", name)
+	args := []string{"toolchain", "install", name, "--profile", "minimal", "--no-self-update"}
+	for _, c := range components {
+		args = append(args, "--component", c)
+	}
+	for _, t := range targets {
+		args = append(args, "--target", t)
+	}
+	return runCmd("", "rustup", args...)
+}
+
+type toolchainFile struct {
+	Channel    string
+	Components []string
+	Targets    []string
+}
+
+func readToolchainFile(repoDir string) (*toolchainFile, string) {
+	for _, name := range []string{"rust-toolchain.toml", "rust-toolchain"} {
+		data, err := os.ReadFile(filepath.Join(repoDir, name))
+		if err != nil {
+			continue
+		}
+		text := strings.TrimSpace(string(data))
+		if !strings.Contains(text, "=") && !strings.Contains(text, "\n") {
+			return &toolchainFile{Channel: text}, name
+		}
+		return parseToolchainTOML(text), name
+	}
+	return nil, ""
+}
+
+func parseToolchainTOML(text string) *toolchainFile {
+	tf := &toolchainFile{}
+	section := ""
+	var pending string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if pending != "" {
+			pending += " " + line
+			if !strings.Contains(line, "]") {
+				continue
+			}
+			line, pending = pending, ""
+		}
+		if strings.HasPrefix(line, "[") && !strings.Contains(line, "=") {
+			section = strings.Trim(line, "[] ")
+			continue
+		}
+		if section != "toolchain" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if strings.HasPrefix(value, "[") && !strings.Contains(value, "]") {
+			pending = line
+			continue
+		}
+		switch key {
+		case "channel":
+			tf.Channel = strings.Trim(value, `"'`)
+		case "components":
+			tf.Components = tomlStringList(value)
+		case "targets":
+			tf.Targets = tomlStringList(value)
+		}
+	}
+	return tf
+}
+
+func tomlStringList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(strings.Trim(value, "[] "), ",") {
+		if item = strings.Trim(strings.TrimSpace(item), `"'`); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func ComponentToolchain(spec *repos.BuildSpec, repoDir string) (string, string) {
+	if spec != nil && spec.Toolchain != "" {
+		return localToolchainName(spec.Toolchain), "repos.json"
+	}
+	if tf, name := readToolchainFile(repoDir); tf != nil && tf.Channel != "" {
+		return localToolchainName(tf.Channel), name
+	}
+	if activeToolchain != "" {
+		return activeToolchain, "-rust-toolchain"
+	}
+	return rustSetup.Toolchain, "-rust-toolchain"
+}
+
+func EnsureComponentToolchain(spec *repos.BuildSpec, repoDir, workDir string, logFn func(string, ...any)) (string, error) {
+	ApplyIsolatedRustEnv(workDir)
+	if _, err := exec.LookPath("rustup"); err != nil {
+		return rustcVersion(), nil
+	}
+	name, source := ComponentToolchain(spec, repoDir)
+	if name != activeToolchain {
+		var components, targets []string
+		if tf, _ := readToolchainFile(repoDir); tf != nil && localToolchainName(tf.Channel) == name {
+			components, targets = tf.Components, tf.Targets
+		}
+		if err := installToolchain(name, components, append(targets, rustTargets...), logFn); err != nil {
+			return "", fmt.Errorf("toolchain %s (from %s): %v", name, source, err)
+		}
+		logFn("Using Rust toolchain %s for %s (from %s)", name, filepath.Base(repoDir), source)
+		recordToolchainPin(name)
+	}
+	cmd := exec.Command("rustc", "--version")
+	cmd.Dir = repoDir
+	cmd.Env = append(os.Environ(), "RUSTUP_TOOLCHAIN="+name)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("rustc %s is not usable: %v", name, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func toolchainEnv(spec *repos.BuildSpec, repoDir string) []string {
+	if _, err := exec.LookPath("rustup"); err != nil {
+		return nil
+	}
+	name, _ := ComponentToolchain(spec, repoDir)
+	return []string{"RUSTUP_TOOLCHAIN=" + name}
+}
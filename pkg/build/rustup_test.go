@@ -0,0 +1,167 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseToolchainTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want toolchainFile
+	}{
+		{
+			name: "channel only",
+			text: "[toolchain]\nchannel = \"1.85.0\"\n",
+			want: toolchainFile{Channel: "1.85.0"},
+		},
+		{
+			name: "inline lists and comments",
+			text: "# pinned for MSRV\n[toolchain]\nchannel = 'nightly-2025-01-15' # dated\ncomponents = [\"rustfmt\", \"clippy\"]\ntargets = [ \"aarch64-unknown-linux-gnu\" ]\nprofile = \"minimal\"\n",
+			want: toolchainFile{Channel: "nightly-2025-01-15", Components: []string{"rustfmt", "clippy"}, Targets: []string{"aarch64-unknown-linux-gnu"}},
+		},
+		{
+			name: "multi-line list",
+			text: "[toolchain]\nchannel = \"stable\"\ncomponents = [\n  \"rust-src\",\n  \"rust-analyzer\", # editor\n]\n",
+			want: toolchainFile{Channel: "stable", Components: []string{"rust-src", "rust-analyzer"}},
+		},
+		{
+			name: "keys outside the toolchain table",
+			text: "channel = \"beta\"\n[other]\nchannel = \"nightly\"\n[toolchain]\ntargets = []\n",
+			want: toolchainFile{},
+		},
+	}
+	for _, tt := range tests {
+		if got := parseToolchainTOML(tt.text); !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("%s: parseToolchainTOML = %+v, want %+v", tt.name, *got, tt.want)
+		}
+	}
+}
+
+func TestReadToolchainFile(t *testing.T) {
+	tests := []struct {
+		file, content string
+		want          *toolchainFile
+	}{
+		{"rust-toolchain", "1.80.1\n", &toolchainFile{Channel: "1.80.1"}},
+		{"rust-toolchain", "[toolchain]\nchannel = \"1.82\"\n", &toolchainFile{Channel: "1.82"}},
+		{"rust-toolchain.toml", "[toolchain]\nchannel = \"stable\"\ncomponents = [\"clippy\"]\n", &toolchainFile{Channel: "stable", Components: []string{"clippy"}}},
+		{"", "", nil},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		if tt.file != "" {
+			if err := os.WriteFile(filepath.Join(dir, tt.file), []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got, name := readToolchainFile(dir)
+		if !reflect.DeepEqual(got, tt.want) || name != tt.file {
+			t.Errorf("readToolchainFile(%s) = %+v, %q, want %+v, %q", tt.file, got, name, tt.want, tt.file)
+		}
+	}
+}
+
+func TestArchiveToolchainName(t *testing.T) {
+	tests := []struct {
+		archive string
+		want    string
+		wantErr bool
+	}{
+		{archive: "/srv/rust-1.85.0-x86_64-unknown-linux-gnu.tar.xz", want: "1.85.0"},
+		{archive: "rust-1.84.1-aarch64-unknown-linux-gnu.tar.gz", want: "1.84.1"},
+		{archive: "rust-nightly-x86_64-unknown-linux-gnu.tar.zst", want: "nightly"},
+		{archive: "rust-1.85.0.tar.xz", wantErr: true},
+		{archive: "rustc-1.85.0-x86_64-unknown-linux-gnu.tar.xz", wantErr: true},
+		{archive: "toolchain.tar.xz", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := archiveToolchainName(tt.archive)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("archiveToolchainName(%q) error = %v, wantErr %v", tt.archive, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("archiveToolchainName(%q) = %q, want %q", tt.archive, got, tt.want)
+		}
+	}
+}
+
+func TestToolchainMatches(t *testing.T) {
+	tests := []struct {
+		installed, name string
+		want            bool
+	}{
+		{"1.85.0", "1.85.0", true},
+		{"1.85.0-x86_64-unknown-linux-gnu", "1.85.0", true},
+		{"1.85.0-x86_64-unknown-linux-gnu", "1.85", false},
+		{"1.85-x86_64-unknown-linux-gnu", "1.85", true},
+		{"stable-x86_64-unknown-linux-gnu", "stable", true},
+		{"nightly-2025-01-15-x86_64-unknown-linux-gnu", "nightly", false},
+		{"nightly-2025-01-15-x86_64-unknown-linux-gnu", "nightly-2025-01-15", true},
+		{"cosmic-deb-1.85.0", "cosmic-deb-1.85.0", true},
+		{"stable", "beta", false},
+	}
+	for _, tt := range tests {
+		if got := toolchainMatches(tt.installed, tt.name); got != tt.want {
+			t.Errorf("toolchainMatches(%q, %q) = %v, want %v", tt.installed, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPinnedBy(t *testing.T) {
+	pins := []string{"1.80.1", "nightly-2025-01-15"}
+	tests := []struct {
+		installed string
+		want      string
+	}{
+		{"1.80.1-x86_64-unknown-linux-gnu", "1.80.1"},
+		{"cosmic-deb-1.80.1", "1.80.1"},
+		{"nightly-2025-01-15-aarch64-unknown-linux-gnu", "nightly-2025-01-15"},
+		{"nightly-x86_64-unknown-linux-gnu", ""},
+		{"1.80.0-x86_64-unknown-linux-gnu", ""},
+		{"cosmic-deb-1.85.0", ""},
+	}
+	for _, tt := range tests {
+		if got := pinnedBy(tt.installed, pins); got != tt.want {
+			t.Errorf("pinnedBy(%q) = %q, want %q", tt.installed, got, tt.want)
+		}
+	}
+}
+
+func TestLocalToolchainName(t *testing.T) {
+	saved := offlineToolchain
+	defer func() { offlineToolchain = saved }()
+	tests := []struct {
+		offline, name, want string
+	}{
+		{"", "1.85.0", "1.85.0"},
+		{"1.85.0", "1.85.0", "cosmic-deb-1.85.0"},
+		{"1.85.0", "stable", "stable"},
+	}
+	for _, tt := range tests {
+		offlineToolchain = tt.offline
+		if got := localToolchainName(tt.name); got != tt.want {
+			t.Errorf("localToolchainName(%q) with offline %q = %q, want %q", tt.name, tt.offline, got, tt.want)
+		}
+	}
+}
+
+func TestRecordToolchainPin(t *testing.T) {
+	withPersistentRustDir(t, "")
+	recordToolchainPin("1.85.0")
+	if pins := pinnedToolchains(); pins != nil {
+		t.Errorf("pins recorded without a persistent directory: %v", pins)
+	}
+
+	withPersistentRustDir(t, t.TempDir())
+	for _, name := range []string{"1.85.0", "nightly-2025-01-15", "1.85.0"} {
+		recordToolchainPin(name)
+	}
+	if got, want := pinnedToolchains(), []string{"1.85.0", "nightly-2025-01-15"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pinnedToolchains = %v, want %v", got, want)
+	}
+}
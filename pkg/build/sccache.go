@@ -0,0 +1,226 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	SccacheDirName        = ".sccache"
+	DefaultSccacheVersion = "0.10.0"
+)
+
+type SccacheConfig struct {
+	Binary    string
+	Version   string
+	Dir       string
+	CacheSize string
+}
+
+type CacheStats struct {
+	Component string
+	Requests  int
+	Hits      int
+	Misses    int
+}
+
+func (s CacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return 100 * float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+func (s CacheStats) String() string {
+	return fmt.Sprintf("%s: %d/%d cache hits (%.1f%%), %d compile requests",
+		s.Component, s.Hits, s.Hits+s.Misses, s.HitRate(), s.Requests)
+}
+
+var (
+	sccache      *SccacheConfig
+	sccacheStats []CacheStats
+)
+
+func SccacheDir(workDir, dir string) string {
+	if dir != "" {
+		if abs, err := filepath.Abs(dir); err == nil {
+			return abs
+		}
+		return dir
+	}
+	return filepath.Join(workDir, SccacheDirName)
+}
+
+func sccacheInstallRoot(cacheDir, version string) string {
+	return filepath.Join(filepath.Dir(cacheDir), "sccache-"+version)
+}
+
+func EnableSccache(workDir, dir, size, version string, logFn func(string, ...any)) error {
+	if version == "" {
+		version = DefaultSccacheVersion
+	}
+	cacheDir := SccacheDir(workDir, dir)
+	root := sccacheInstallRoot(cacheDir, version)
+	EnsureCargoBinInPath(workDir)
+	bin := filepath.Join(root, "bin", "sccache")
+	if _, err := os.Stat(bin); err != nil {
+		system, _ := exec.LookPath("sccache")
+		if system != "" && sccacheBinaryVersion(system) == version {
+			bin = system
+		} else {
+			logFn("Installing sccache %s via cargo into %s", version, root)
+			if err := runCmd("", "cargo", "install", "--locked", "--version", version, "--root", root, "sccache"); err != nil {
+				if system == "" {
+					return fmt.Errorf("cannot install sccache %s: %v", version, err)
+				}
+				logFn("WARNING: Cannot install sccache %s (%v); falling back to unpinned %s", version, err, system)
+				bin = system
+			}
+		}
+	}
+	actual := sccacheBinaryVersion(bin)
+	if actual != version {
+		logFn("WARNING: Using sccache %s from %s, expected %s (-sccache-version)", actual, bin, version)
+	}
+	cfg := &SccacheConfig{Binary: bin, Version: actual, Dir: cacheDir, CacheSize: size}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return err
+	}
+	_ = exec.Command(bin, "--stop-server").Run()
+	start := exec.Command(bin, "--start-server")
+	start.Env = append(os.Environ(), "SCCACHE_DIR="+cfg.Dir)
+	if cfg.CacheSize != "" {
+		start.Env = append(start.Env, "SCCACHE_CACHE_SIZE="+cfg.CacheSize)
+	}
+	if out, err := start.CombinedOutput(); err != nil {
+		return fmt.Errorf("sccache server failed to start: %s", strings.TrimSpace(string(out)))
+	}
+	sccache = cfg
+	ApplyIsolatedRustEnv(workDir)
+	logFn("sccache %s enabled: %s (cache %s, max %s)", actual, bin, cfg.Dir, cfg.CacheSize)
+	return nil
+}
+
+func sccacheBinaryVersion(bin string) string {
+	out, err := exec.Command(bin, "--version").Output()
+	if err != nil {
+		return "unknown"
+	}
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(out)), "sccache"))
+}
+
+func SccacheVersion() string {
+	if sccache == nil {
+		return ""
+	}
+	return sccache.Version
+}
+
+func SccacheEnabled() bool {
+	return sccache != nil
+}
+
+func applySccacheEnv() {
+	if sccache == nil {
+		return
+	}
+	os.Setenv("RUSTC_WRAPPER", sccache.Binary)
+	os.Setenv("SCCACHE_DIR", sccache.Dir)
+	if sccache.CacheSize != "" {
+		os.Setenv("SCCACHE_CACHE_SIZE", sccache.CacheSize)
+	}
+	for _, v := range []struct{ name, def string }{{"CC", "cc"}, {"CXX", "c++"}} {
+		current := os.Getenv(v.name)
+		if strings.HasPrefix(current, sccache.Binary+" ") {
+			continue
+		}
+		if current == "" {
+			current = v.def
+		}
+		os.Setenv(v.name, sccache.Binary+" "+current)
+	}
+}
+
+func sccacheZeroStats() {
+	if sccache == nil {
+		return
+	}
+	_ = exec.Command(sccache.Binary, "--zero-stats").Run()
+}
+
+type sccacheJSON struct {
+	Stats struct {
+		CompileRequests int `json:"compile_requests"`
+		CacheHits       struct {
+			Counts map[string]int `json:"counts"`
+		} `json:"cache_hits"`
+		CacheMisses struct {
+			Counts map[string]int `json:"counts"`
+		} `json:"cache_misses"`
+	} `json:"stats"`
+}
+
+func sccacheRecordStats(component string) {
+	if sccache == nil {
+		return
+	}
+	out, err := exec.Command(sccache.Binary, "--show-stats", "--stats-format", "json").Output()
+	if err != nil {
+		return
+	}
+	var parsed sccacheJSON
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		return
+	}
+	st := CacheStats{Component: component, Requests: parsed.Stats.CompileRequests}
+	for _, n := range parsed.Stats.CacheHits.Counts {
+		st.Hits += n
+	}
+	for _, n := range parsed.Stats.CacheMisses.Counts {
+		st.Misses += n
+	}
+	for i := range sccacheStats {
+		if sccacheStats[i].Component == component {
+			sccacheStats[i] = st
+			return
+		}
+	}
+	sccacheStats = append(sccacheStats, st)
+}
+
+func ComponentCacheStats(component string) (CacheStats, bool) {
+	for _, s := range sccacheStats {
+		if s.Component == component {
+			return s, true
+		}
+	}
+	return CacheStats{}, false
+}
+
+func SccacheReport(logFn func(string, ...any)) {
+	if sccache == nil || len(sccacheStats) == 0 {
+		return
+	}
+	total := CacheStats{Component: "total"}
+	for _, s := range sccacheStats {
+		logFn("sccache %s", s)
+		total.Requests += s.Requests
+		total.Hits += s.Hits
+		total.Misses += s.Misses
+	}
+	logFn("sccache %s", total)
+	if usage := dirSize(sccache.Dir); usage > 0 {
+		logFn("sccache cache directory %s: %s", sccache.Dir, formatSize(usage))
+	}
+}
+
+func StopSccache() {
+	if sccache == nil {
+		return
+	}
+	_ = exec.Command(sccache.Binary, "--stop-server").Run()
+}
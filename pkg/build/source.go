@@ -21,6 +21,32 @@ func ArchiveURL(repo repos.Entry, tag string) string {
 	return fmt.Sprintf("%s/archive/refs/heads/%s.tar.gz", repo.URL, branch)
 }
 
+func ControlURL(repo repos.Entry, tag string) string {
+	ref := tag
+	kind := "tag"
+	if ref == "" {
+		ref = repo.Branch
+		if ref == "" {
+			ref = repos.DefaultBranch(repo.URL)
+		}
+		kind = "branch"
+	}
+	base := strings.TrimSuffix(repo.URL, ".git")
+	if strings.HasPrefix(base, "https://github.com/") {
+		return fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/debian/control", strings.TrimPrefix(base, "https://github.com/"), ref)
+	}
+	return fmt.Sprintf("%s/raw/%s/%s/debian/control", base, kind, ref)
+}
+
+func FetchControl(repo repos.Entry, tag string) (string, error) {
+	cmd := exec.Command("curl", "-fsSL", "--max-time", "30", ControlURL(repo, tag))
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch debian/control for %s: %v", repo.Name, err)
+	}
+	return string(out), nil
+}
+
 func detectExtractedDir(workDir, tarPath string) (string, error) {
 	cmd := exec.Command("tar", "-tzf", tarPath)
 	out, err := cmd.Output()
@@ -0,0 +1,204 @@
+package build
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jimed-rand/cosmic-deb/pkg/cargo"
+)
+
+const SharedTargetDirName = ".cargo-target"
+
+var SharedTargetModes = []string{"off", "single", "bucket"}
+
+var bucketAnchorCrates = []string{"libcosmic"}
+
+var sharedTargetMode = "off"
+
+func SharedTargetRoot(workDir string) string {
+	return filepath.Join(workDir, SharedTargetDirName)
+}
+
+func ConfigureSharedTarget(mode string) error {
+	for _, m := range SharedTargetModes {
+		if m == mode {
+			sharedTargetMode = mode
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown shared target mode '%s' (known: %s)", mode, strings.Join(SharedTargetModes, ", "))
+}
+
+func SharingTarget() bool {
+	return sharedTargetMode != "off"
+}
+
+func targetBucket(repoDir string) string {
+	if sharedTargetMode == "single" {
+		return "shared"
+	}
+	lock, err := cargo.ParseLock(filepath.Join(repoDir, "Cargo.lock"))
+	if err != nil {
+		return "default"
+	}
+	var revs []string
+	for _, p := range lock.Packages {
+		for _, anchor := range bucketAnchorCrates {
+			if p.Name != anchor {
+				continue
+			}
+			rev := p.Version
+			if _, commit, ok := strings.Cut(p.Source, "#"); ok && commit != "" {
+				rev = commit
+				if len(rev) > 12 {
+					rev = rev[:12]
+				}
+			}
+			revs = append(revs, anchor+"-"+rev)
+		}
+	}
+	if len(revs) == 0 {
+		return "default"
+	}
+	sort.Strings(revs)
+	return strings.Join(revs, "+")
+}
+
+func sharedTargetDir(repoDir, workDir string) string {
+	if !SharingTarget() {
+		return ""
+	}
+	return filepath.Join(SharedTargetRoot(workDir), targetBucket(repoDir))
+}
+
+type cargoMetadata struct {
+	Packages []struct {
+		Targets []struct {
+			Name string   `json:"name"`
+			Kind []string `json:"kind"`
+		} `json:"targets"`
+	} `json:"packages"`
+}
+
+func cargoProducts(repoDir string) (map[string]bool, error) {
+	cmd := exec.Command("cargo", "metadata", "--no-deps", "--offline", "--format-version", "1")
+	cmd.Dir = repoDir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("cargo metadata: %v", err)
+	}
+	var meta cargoMetadata
+	if err := json.Unmarshal(out, &meta); err != nil {
+		return nil, err
+	}
+	products := make(map[string]bool)
+	for _, p := range meta.Packages {
+		for _, t := range p.Targets {
+			for _, k := range t.Kind {
+				switch k {
+				case "bin":
+					products[t.Name] = true
+				case "cdylib":
+					products["lib"+strings.ReplaceAll(t.Name, "-", "_")+".so"] = true
+				}
+			}
+		}
+	}
+	return products, nil
+}
+
+func publishSharedArtifacts(repoDir, targetDir string) (int, error) {
+	products, err := cargoProducts(repoDir)
+	if err != nil {
+		return 0, err
+	}
+	srcDir := filepath.Join(targetDir, "release")
+	if activeCross != nil {
+		srcDir = filepath.Join(targetDir, activeCross.RustTriple, "release")
+	}
+	dstDir := filepath.Join(repoDir, "target", "release")
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		return 0, err
+	}
+	n := 0
+	for name := range products {
+		src := filepath.Join(srcDir, name)
+		info, err := os.Stat(src)
+		if err != nil {
+			continue
+		}
+		dst := filepath.Join(dstDir, name)
+		_ = os.Remove(dst)
+		if err := os.Link(src, dst); err != nil {
+			if err := copyFile(src, dst, info.Mode()); err != nil {
+				return n, err
+			}
+		}
+		n++
+	}
+	return n, nil
+}
+
+func dirSize(root string) int64 {
+	var total int64
+	_ = filepath.WalkDir(root, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
+
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func ReportSharedTarget(workDir string, logFn func(string, ...any)) {
+	root := SharedTargetRoot(workDir)
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	var total int64
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		size := dirSize(filepath.Join(root, e.Name()))
+		total += size
+		logFn("Shared target bucket %s: %s", e.Name(), formatSize(size))
+	}
+	logFn("Shared target directory %s: %s in %d bucket(s)", root, formatSize(total), len(entries))
+}
+
+func CleanSharedTarget(workDir string, logFn func(string, ...any)) error {
+	root := SharedTargetRoot(workDir)
+	if _, err := os.Stat(root); err != nil {
+		return nil
+	}
+	size := dirSize(root)
+	if err := os.RemoveAll(root); err != nil {
+		return err
+	}
+	logFn("Removed shared target directory %s (%s freed)", root, formatSize(size))
+	return nil
+}
@@ -0,0 +1,175 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const pinnedToolchainsFile = "pinned-toolchains"
+
+var (
+	persistentRustDir string
+	toolchainUpdate   bool
+)
+
+var toolchainCacheDirs = []string{
+	filepath.Join(".cargo", "registry", "cache"),
+	filepath.Join(".cargo", "registry", "src"),
+	filepath.Join(".cargo", "git", "checkouts"),
+	filepath.Join(".rustup", "downloads"),
+	filepath.Join(".rustup", "tmp"),
+}
+
+func DefaultToolchainDir() string {
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "cosmic-deb", "rust")
+	}
+	return filepath.Join(os.TempDir(), "cosmic-deb-rust")
+}
+
+func ConfigurePersistentToolchain(dir string, update bool) (string, error) {
+	if dir == "" {
+		dir = DefaultToolchainDir()
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if home, err := os.UserHomeDir(); err == nil && filepath.Clean(home) == abs {
+		return "", fmt.Errorf("toolchain directory %s would reuse ~/.cargo and ~/.rustup; choose a dedicated directory", abs)
+	}
+	if err := os.MkdirAll(abs, 0755); err != nil {
+		return "", fmt.Errorf("cannot create toolchain directory '%s': %v", abs, err)
+	}
+	persistentRustDir = abs
+	toolchainUpdate = update
+	return abs, nil
+}
+
+func PersistentToolchain() bool {
+	return persistentRustDir != ""
+}
+
+func rustEnvRoot(workDir string) string {
+	root := workDir
+	if persistentRustDir != "" {
+		root = persistentRustDir
+	}
+	if abs, err := filepath.Abs(root); err == nil {
+		return abs
+	}
+	return root
+}
+
+func rustcVersion() string {
+	out, err := exec.Command("rustc", "--version").Output()
+	if err != nil {
+		return "unknown rustc"
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func checkToolchainUpdates(logFn func(string, ...any)) error {
+	if toolchainUpdate {
+		logFn("Updating the persistent Rust toolchain via rustup update")
+		if err := runCmd("", "rustup", "update"); err != nil {
+			return err
+		}
+		logFn("Persistent Rust toolchain now at %s", rustcVersion())
+		return nil
+	}
+	out, err := exec.Command("rustup", "check").CombinedOutput()
+	if err != nil {
+		logFn("WARNING: Cannot check for Rust toolchain updates: %v", err)
+		return nil
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.Contains(line, "Update available") {
+			logFn("Rust toolchain update available: %s (rerun with -toolchain-update)", strings.TrimSpace(line))
+		}
+	}
+	return nil
+}
+
+func pinnedToolchains() []string {
+	if persistentRustDir == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(persistentRustDir, pinnedToolchainsFile))
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(string(data))
+}
+
+func recordToolchainPin(name string) {
+	if persistentRustDir == "" {
+		return
+	}
+	for _, p := range pinnedToolchains() {
+		if p == name {
+			return
+		}
+	}
+	f, err := os.OpenFile(filepath.Join(persistentRustDir, pinnedToolchainsFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, name)
+}
+
+func pinnedBy(installed string, pins []string) string {
+	for _, p := range pins {
+		if toolchainMatches(installed, p) || toolchainMatches(installed, offlineToolchainPrefix+p) {
+			return p
+		}
+	}
+	return ""
+}
+
+func CollectToolchainGarbage(keep []string, logFn func(string, ...any)) error {
+	if persistentRustDir == "" {
+		return fmt.Errorf("no persistent toolchain directory configured")
+	}
+	ApplyIsolatedRustEnv(persistentRustDir)
+	keep = append(pinnedToolchains(), keep...)
+	before := dirSize(persistentRustDir)
+	if _, err := exec.LookPath("rustup"); err == nil {
+		out, err := exec.Command("rustup", "toolchain", "list").Output()
+		if err != nil {
+			return fmt.Errorf("rustup toolchain list: %v", err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 0 || strings.Contains(line, "(default)") || strings.Contains(line, "no installed toolchains") {
+				continue
+			}
+			if pin := pinnedBy(fields[0], keep); pin != "" {
+				logFn("Keeping Rust toolchain %s (pinned as %s)", fields[0], pin)
+				continue
+			}
+			logFn("Removing unused Rust toolchain %s", fields[0])
+			if err := runCmd("", "rustup", "toolchain", "uninstall", fields[0]); err != nil {
+				return err
+			}
+		}
+	}
+	for _, rel := range toolchainCacheDirs {
+		dir := filepath.Join(persistentRustDir, rel)
+		size := dirSize(dir)
+		if size == 0 {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return err
+		}
+		logFn("Removed %s (%s)", dir, formatSize(size))
+	}
+	after := dirSize(persistentRustDir)
+	logFn("Persistent Rust toolchain %s: %s (%s freed)", persistentRustDir, formatSize(after), formatSize(before-after))
+	return nil
+}
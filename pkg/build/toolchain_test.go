@@ -0,0 +1,69 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func withPersistentRustDir(t *testing.T, dir string) {
+	t.Helper()
+	savedDir, savedUpdate := persistentRustDir, toolchainUpdate
+	t.Cleanup(func() { persistentRustDir, toolchainUpdate = savedDir, savedUpdate })
+	persistentRustDir = dir
+}
+
+func TestConfigurePersistentToolchain(t *testing.T) {
+	withPersistentRustDir(t, "")
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tests := []struct {
+		name    string
+		dir     string
+		wantErr bool
+	}{
+		{name: "dedicated directory", dir: filepath.Join(home, "rust")},
+		{name: "nested directory", dir: filepath.Join(home, "cache", "cosmic-deb", "rust")},
+		{name: "home directory", dir: home, wantErr: true},
+		{name: "home directory with trailing slash", dir: home + "/", wantErr: true},
+	}
+	for _, tt := range tests {
+		persistentRustDir = ""
+		got, err := ConfigurePersistentToolchain(tt.dir, true)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: ConfigurePersistentToolchain error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			if PersistentToolchain() {
+				t.Errorf("%s: persistent mode enabled despite the error", tt.name)
+			}
+			continue
+		}
+		if got != filepath.Clean(tt.dir) || !PersistentToolchain() || !toolchainUpdate {
+			t.Errorf("%s: got %q, persistent %v, update %v", tt.name, got, PersistentToolchain(), toolchainUpdate)
+		}
+		if info, err := os.Stat(got); err != nil || !info.IsDir() {
+			t.Errorf("%s: %s was not created", tt.name, got)
+		}
+	}
+}
+
+func TestRustEnvRoot(t *testing.T) {
+	work := t.TempDir()
+	persistent := t.TempDir()
+	tests := []struct {
+		persistent string
+		want       string
+	}{
+		{"", work},
+		{persistent, persistent},
+	}
+	for _, tt := range tests {
+		withPersistentRustDir(t, tt.persistent)
+		if got := rustEnvRoot(work); got != tt.want {
+			t.Errorf("rustEnvRoot with persistent dir %q = %q, want %q", tt.persistent, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package cargo
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const CratesIORegistry = "registry+https://github.com/rust-lang/crates.io-index"
+
+type Package struct {
+	Name         string
+	Version      string
+	Source       string
+	Checksum     string
+	Dependencies []string
+}
+
+type Lock struct {
+	Version  int
+	Packages []Package
+}
+
+func (p Package) IsLocal() bool {
+	return p.Source == ""
+}
+
+func (p Package) IsCratesIO() bool {
+	return p.Source == CratesIORegistry
+}
+
+func (p Package) PURL() string {
+	purl := fmt.Sprintf("pkg:cargo/%s@%s", p.Name, p.Version)
+	switch {
+	case p.IsLocal(), p.IsCratesIO():
+		return purl
+	case strings.HasPrefix(p.Source, "git+"):
+		repo, commit, _ := strings.Cut(p.Source, "#")
+		if idx := strings.Index(repo, "?"); idx > 0 {
+			repo = repo[:idx]
+		}
+		if commit != "" {
+			repo += "@" + commit
+		}
+		return purl + "?vcs_url=" + url.QueryEscape(repo)
+	}
+	return purl
+}
+
+func ParseLock(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lock := &Lock{}
+	var cur *Package
+	inDeps := false
+	flush := func() {
+		if cur != nil && cur.Name != "" {
+			lock.Packages = append(lock.Packages, *cur)
+		}
+		cur = nil
+	}
+	for n, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if inDeps {
+			if strings.HasPrefix(line, "]") {
+				inDeps = false
+				continue
+			}
+			if dep := strings.Trim(strings.TrimSuffix(line, ","), `"`); dep != "" && cur != nil {
+				cur.Dependencies = append(cur.Dependencies, dep)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			flush()
+			if line == "[[package]]" {
+				cur = &Package{}
+			}
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: malformed line %q", path, n+1, line)
+		}
+		key = strings.TrimSpace(key)
+		val = strings.TrimSpace(val)
+		if cur == nil {
+			if key == "version" {
+				fmt.Sscanf(val, "%d", &lock.Version)
+			}
+			continue
+		}
+		switch key {
+		case "name":
+			cur.Name = unquote(val)
+		case "version":
+			cur.Version = unquote(val)
+		case "source":
+			cur.Source = unquote(val)
+		case "checksum":
+			cur.Checksum = unquote(val)
+		case "dependencies":
+			if strings.HasSuffix(val, "]") {
+				for _, dep := range strings.Split(strings.Trim(val, "[]"), ",") {
+					if dep = unquote(strings.TrimSpace(dep)); dep != "" {
+						cur.Dependencies = append(cur.Dependencies, dep)
+					}
+				}
+			} else {
+				inDeps = true
+			}
+		}
+	}
+	flush()
+	return lock, nil
+}
+
+func (l *Lock) Resolve(dep string) (Package, bool) {
+	fields := strings.Fields(dep)
+	if len(fields) == 0 {
+		return Package{}, false
+	}
+	var match []Package
+	for _, p := range l.Packages {
+		if p.Name != fields[0] {
+			continue
+		}
+		if len(fields) > 1 && p.Version != fields[1] {
+			continue
+		}
+		if len(fields) > 2 && "("+p.Source+")" != fields[2] {
+			continue
+		}
+		match = append(match, p)
+	}
+	if len(match) != 1 {
+		return Package{}, false
+	}
+	return match[0], true
+}
+
+type VendoredCrate struct {
+	Name    string
+	Version string
+}
+
+func VendorInventory(repoDir string) (map[string]VendoredCrate, error) {
+	crates := make(map[string]VendoredCrate)
+	vendorDir := filepath.Join(repoDir, "vendor")
+	if entries, err := os.ReadDir(vendorDir); err == nil {
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(vendorDir, e.Name(), "Cargo.toml"))
+			if err != nil {
+				continue
+			}
+			if c, ok := crateFromManifest(string(data)); ok {
+				crates[c.Name+"@"+c.Version] = c
+			}
+		}
+		return crates, nil
+	}
+	vendorTar := filepath.Join(repoDir, "vendor.tar")
+	f, err := os.Open(vendorTar)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return crates, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %v", vendorTar, err)
+		}
+		parts := strings.Split(strings.TrimPrefix(hdr.Name, "./"), "/")
+		if len(parts) != 3 || parts[0] != "vendor" || parts[2] != "Cargo.toml" {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := crateFromManifest(string(data)); ok {
+			crates[c.Name+"@"+c.Version] = c
+		}
+	}
+	return crates, nil
+}
+
+func SortedPackages(l *Lock) []Package {
+	pkgs := append([]Package(nil), l.Packages...)
+	sort.Slice(pkgs, func(i, j int) bool {
+		if pkgs[i].Name != pkgs[j].Name {
+			return pkgs[i].Name < pkgs[j].Name
+		}
+		return pkgs[i].Version < pkgs[j].Version
+	})
+	return pkgs
+}
+
+func crateFromManifest(manifest string) (VendoredCrate, bool) {
+	var c VendoredCrate
+	inPackage := false
+	for _, line := range strings.Split(manifest, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inPackage = line == "[package]"
+			continue
+		}
+		if !inPackage {
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "name":
+			c.Name = unquote(strings.TrimSpace(val))
+		case "version":
+			c.Version = unquote(strings.TrimSpace(val))
+		}
+	}
+	return c, c.Name != "" && c.Version != ""
+}
+
+func unquote(s string) string {
+	return strings.Trim(s, `"'`)
+}
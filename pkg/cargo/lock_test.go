@@ -0,0 +1,132 @@
+package cargo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testLock = `# This file is automatically @generated by Cargo.
+# It is not intended for manual editing.
+version = 4
+
+[[package]]
+name = "app"
+version = "0.1.0"
+dependencies = [
+ "serde",
+ "smithay 0.3.0 (git+https://github.com/Smithay/smithay?rev=abc#abc123)",
+]
+
+[[package]]
+name = "serde"
+version = "1.0.200"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+checksum = "deadbeef"
+
+[[package]]
+name = "smithay"
+version = "0.3.0"
+source = "git+https://github.com/Smithay/smithay?rev=abc#abc123"
+dependencies = ["serde"]
+`
+
+func writeLock(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "Cargo.lock")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseLock(t *testing.T) {
+	lock, err := ParseLock(writeLock(t, testLock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.Version != 4 {
+		t.Errorf("Version = %d, want 4", lock.Version)
+	}
+	want := []Package{
+		{Name: "app", Version: "0.1.0", Dependencies: []string{"serde", "smithay 0.3.0 (git+https://github.com/Smithay/smithay?rev=abc#abc123)"}},
+		{Name: "serde", Version: "1.0.200", Source: CratesIORegistry, Checksum: "deadbeef"},
+		{Name: "smithay", Version: "0.3.0", Source: "git+https://github.com/Smithay/smithay?rev=abc#abc123", Dependencies: []string{"serde"}},
+	}
+	if !reflect.DeepEqual(lock.Packages, want) {
+		t.Errorf("Packages = %+v, want %+v", lock.Packages, want)
+	}
+}
+
+func TestParseLockMalformed(t *testing.T) {
+	if _, err := ParseLock(writeLock(t, "[[package]]\nname \"broken\"\n")); err == nil {
+		t.Error("ParseLock succeeded on a malformed line, want error")
+	}
+}
+
+func TestLockResolve(t *testing.T) {
+	lock := &Lock{Packages: []Package{
+		{Name: "serde", Version: "1.0.200", Source: CratesIORegistry},
+		{Name: "syn", Version: "1.0.109", Source: CratesIORegistry},
+		{Name: "syn", Version: "2.0.60", Source: CratesIORegistry},
+	}}
+	tests := []struct {
+		dep    string
+		want   string
+		wantOK bool
+	}{
+		{dep: "serde", want: "1.0.200", wantOK: true},
+		{dep: "syn", wantOK: false},
+		{dep: "syn 2.0.60", want: "2.0.60", wantOK: true},
+		{dep: "syn 1.0.109 (" + CratesIORegistry + ")", want: "1.0.109", wantOK: true},
+		{dep: "syn 1.0.109 (git+https://example.com/syn)", wantOK: false},
+		{dep: "missing", wantOK: false},
+		{dep: "", wantOK: false},
+	}
+	for _, tt := range tests {
+		got, ok := lock.Resolve(tt.dep)
+		if ok != tt.wantOK || (ok && got.Version != tt.want) {
+			t.Errorf("Resolve(%q) = %s, %v, want %s, %v", tt.dep, got.Version, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestPackagePURL(t *testing.T) {
+	tests := []struct {
+		pkg  Package
+		want string
+	}{
+		{Package{Name: "app", Version: "0.1.0"}, "pkg:cargo/app@0.1.0"},
+		{Package{Name: "serde", Version: "1.0.200", Source: CratesIORegistry}, "pkg:cargo/serde@1.0.200"},
+		{
+			Package{Name: "smithay", Version: "0.3.0", Source: "git+https://github.com/Smithay/smithay?rev=abc#abc123"},
+			"pkg:cargo/smithay@0.3.0?vcs_url=git%2Bhttps%3A%2F%2Fgithub.com%2FSmithay%2Fsmithay%40abc123",
+		},
+		{Package{Name: "local", Version: "1.0.0", Source: "registry+https://example.com/index"}, "pkg:cargo/local@1.0.0"},
+	}
+	for _, tt := range tests {
+		if got := tt.pkg.PURL(); got != tt.want {
+			t.Errorf("PURL(%+v) = %q, want %q", tt.pkg, got, tt.want)
+		}
+	}
+}
+
+func TestCrateFromManifest(t *testing.T) {
+	tests := []struct {
+		manifest string
+		want     VendoredCrate
+		wantOK   bool
+	}{
+		{"[package]\nname = \"serde\"\nversion = \"1.0.200\"\n", VendoredCrate{"serde", "1.0.200"}, true},
+		{"[package]\nname = 'foo'\nedition = \"2021\"\nversion = '0.2.0'\n\n[dependencies]\nname = \"bar\"\n", VendoredCrate{"foo", "0.2.0"}, true},
+		{"[dependencies]\nname = \"serde\"\nversion = \"1\"\n", VendoredCrate{}, false},
+		{"[package]\nname = \"nover\"\n", VendoredCrate{Name: "nover"}, false},
+	}
+	for _, tt := range tests {
+		got, ok := crateFromManifest(tt.manifest)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("crateFromManifest(%q) = %+v, %v, want %+v, %v", tt.manifest, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package debian
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+const ArchAll = "all"
+
+var goArchToDebian = map[string]string{
+	"amd64":    "amd64",
+	"arm64":    "arm64",
+	"386":      "i386",
+	"arm":      "armhf",
+	"riscv64":  "riscv64",
+	"ppc64le":  "ppc64el",
+	"s390x":    "s390x",
+	"loong64":  "loong64",
+	"mips64le": "mips64el",
+}
+
+func HostArch() string {
+	if out, err := exec.Command("dpkg", "--print-architecture").Output(); err == nil {
+		if arch := strings.TrimSpace(string(out)); arch != "" {
+			return arch
+		}
+	}
+	if arch, ok := goArchToDebian[runtime.GOARCH]; ok {
+		return arch
+	}
+	return runtime.GOARCH
+}
+
+func elfArch(f *elf.File) string {
+	switch f.Machine {
+	case elf.EM_X86_64:
+		return "amd64"
+	case elf.EM_AARCH64:
+		return "arm64"
+	case elf.EM_386:
+		return "i386"
+	case elf.EM_ARM:
+		return "armhf"
+	case elf.EM_RISCV:
+		if f.Class == elf.ELFCLASS64 {
+			return "riscv64"
+		}
+	case elf.EM_PPC64:
+		if f.ByteOrder == binary.LittleEndian {
+			return "ppc64el"
+		}
+		return "ppc64"
+	case elf.EM_S390:
+		return "s390x"
+	case elf.EM_LOONGARCH:
+		return "loong64"
+	case elf.EM_MIPS:
+		if f.Class == elf.ELFCLASS64 && f.ByteOrder == binary.LittleEndian {
+			return "mips64el"
+		}
+	}
+	return "unknown-" + strings.ToLower(strings.TrimPrefix(f.Machine.String(), "EM_"))
+}
+
+func ScanELFArchs(stageDir string) (map[string][]string, error) {
+	found := make(map[string][]string)
+	err := filepath.WalkDir(stageDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != stageDir && d.Name() == "DEBIAN" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		arch, ok := fileELFArch(path)
+		if !ok {
+			return nil
+		}
+		rel, _ := filepath.Rel(stageDir, path)
+		found[arch] = append(found[arch], rel)
+		return nil
+	})
+	return found, err
+}
+
+func fileELFArch(path string) (string, bool) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer fh.Close()
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(fh, magic); err != nil || !bytes.Equal(magic, []byte(elf.ELFMAG)) {
+		return "", false
+	}
+	f, err := elf.NewFile(fh)
+	if err != nil {
+		return "", false
+	}
+	return elfArch(f), true
+}
+
+func ResolveArch(stageDir, targetArch string) (string, error) {
+	found, err := ScanELFArchs(stageDir)
+	if err != nil {
+		return targetArch, err
+	}
+	if len(found) == 0 {
+		return ArchAll, nil
+	}
+	if len(found) == 1 {
+		for arch, files := range found {
+			if arch != targetArch {
+				return arch, fmt.Errorf("staged binaries are %s (e.g. %s) but the target architecture is %s", arch, files[0], targetArch)
+			}
+			return arch, nil
+		}
+	}
+	var archs []string
+	for arch, files := range found {
+		archs = append(archs, fmt.Sprintf("%s (%s)", arch, files[0]))
+	}
+	sort.Strings(archs)
+	return targetArch, fmt.Errorf("staging tree mixes ELF architectures: %s", strings.Join(archs, ", "))
+}
@@ -5,7 +5,6 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strings"
 )
 
@@ -20,7 +19,7 @@ var RuntimeDeps = map[string][]string{
 	},
 	"cosmic-files":           {"xdg-utils"},
 	"cosmic-applets":         {"cosmic-icons"},
-	"cosmic-greeter":         {"adduser", "cosmic-comp", "cosmic-greeter-daemon", "cosmic-randr", "dbus"},
+	"cosmic-greeter":         {"adduser", "cosmic-comp", "cosmic-randr", "dbus"},
 	"cosmic-settings":        {"accountsservice", "cosmic-randr", "gettext", "iso-codes", "network-manager-gnome", "network-manager-openvpn", "network-manager-openvpn-gnome", "xkb-data"},
 	"cosmic-settings-daemon": {"acpid"},
 	"cosmic-osd":             {"pulseaudio-utils"},
@@ -41,6 +40,15 @@ var Recommends = map[string][]string{
 	"cosmic-greeter":         {"xinit"},
 }
 
+type Overrides struct {
+	Depends     []string
+	Recommends  []string
+	Conflicts   []string
+	Section     string
+	Description string
+	Patches     []string
+}
+
 var adminSection = map[string]bool{
 	"cosmic-session": true, "cosmic-files": true, "cosmic-applets": true, "cosmic-edit": true,
 	"cosmic-store": true, "cosmic-bg": true, "cosmic-greeter": true, "cosmic-icons": true,
@@ -64,13 +72,6 @@ func sectionFor(pkgName string) string {
 	return "x11"
 }
 
-func archString() string {
-	if runtime.GOARCH == "arm64" {
-		return "arm64"
-	}
-	return "amd64"
-}
-
 func runDpkg(args ...string) error {
 	cmd := exec.Command(args[0], args[1:]...)
 	cmd.Env = os.Environ()
@@ -92,59 +93,76 @@ func StagingHasContent(stageDir string) bool {
 	return false
 }
 
-func fileVersion(version, distroCodename string) string {
+func FileVersion(version, distroCodename string) string {
 	if distroCodename != "" {
 		return version + "~" + distroCodename
 	}
 	return version
 }
 
-func BuildPackage(stageDir, outDir, pkgName, version, distroCodename, maintainerName, maintainerEmail string) error {
+func BuildPackage(stageDir, outDir, pkgName, version, arch, distroCodename, maintainerName, maintainerEmail string, ov Overrides, extraDeps, extraRecs []string) (string, error) {
 	debianDir := filepath.Join(stageDir, "DEBIAN")
 	if err := os.MkdirAll(debianDir, 0755); err != nil {
-		return err
+		return "", err
 	}
-	arch := archString()
-	fv := fileVersion(version, distroCodename)
+	fv := FileVersion(version, distroCodename)
 
 	depEntries := []string{"${shlibs:Depends}"}
 	if deps, ok := RuntimeDeps[pkgName]; ok {
 		depEntries = append(depEntries, deps...)
 	}
+	depEntries = append(depEntries, extraDeps...)
+	depEntries = append(depEntries, ov.Depends...)
 
+	section := sectionFor(pkgName)
+	if ov.Section != "" {
+		section = ov.Section
+	}
 	control := fmt.Sprintf("Package: %s\nVersion: %s\nSection: %s\nPriority: optional\nArchitecture: %s\nDepends: %s\n",
-		pkgName, fv, sectionFor(pkgName), arch, strings.Join(depEntries, ", "))
+		pkgName, fv, section, arch, strings.Join(depEntries, ", "))
 
-	if recs, ok := Recommends[pkgName]; ok && len(recs) > 0 {
+	recs := append(append([]string(nil), Recommends[pkgName]...), ov.Recommends...)
+	recs = append(recs, extraRecs...)
+	if len(recs) > 0 {
 		control += fmt.Sprintf("Recommends: %s\n", strings.Join(recs, ", "))
 	}
+	if len(ov.Conflicts) > 0 {
+		control += fmt.Sprintf("Conflicts: %s\n", strings.Join(ov.Conflicts, ", "))
+	}
 
-	control += fmt.Sprintf("Maintainer: %s <%s>\nDescription: COSMIC Desktop Environment component — %s\n Built from upstream source via the cosmic-deb build tool.\n",
-		maintainerName, maintainerEmail, pkgName)
+	synopsis := "COSMIC Desktop Environment component — " + pkgName
+	if ov.Description != "" {
+		synopsis = ov.Description
+	}
+	control += fmt.Sprintf("Maintainer: %s <%s>\nDescription: %s\n Built from upstream source via the cosmic-deb build tool.\n",
+		maintainerName, maintainerEmail, synopsis)
+	if patches := ov.Patches; len(patches) > 0 {
+		control += fmt.Sprintf(" Local patches: %s.\n", strings.Join(patches, ", "))
+	}
 
 	if err := os.WriteFile(filepath.Join(debianDir, "control"), []byte(control), 0644); err != nil {
-		return err
+		return "", err
 	}
 
 	pkgFile := filepath.Join(outDir, fmt.Sprintf("%s_%s_%s.deb", pkgName, fv, arch))
-	return runDpkg("fakeroot", "dpkg-deb", "--build", stageDir, pkgFile)
+	return pkgFile, runDpkg("fakeroot", "dpkg-deb", "--build", stageDir, pkgFile)
 }
 
-func BuildMetaPackage(workDir, outDir, version, distroCodename, maintainerName, maintainerEmail string, builtRepos []string) error {
+func BuildMetaPackage(workDir, outDir, version, distroCodename, maintainerName, maintainerEmail string, builtRepos []string) (string, error) {
 	const metaPkg = "cosmic-desktop"
-	arch := archString()
-	fv := fileVersion(version, distroCodename)
+	arch := ArchAll
+	fv := FileVersion(version, distroCodename)
 	stageDir := filepath.Join(workDir, metaPkg+"-stage")
 	if err := os.MkdirAll(filepath.Join(stageDir, "DEBIAN"), 0755); err != nil {
-		return err
+		return "", err
 	}
 
 	control := fmt.Sprintf("Package: %s\nVersion: %s\nSection: x11\nPriority: optional\nArchitecture: %s\nDepends: %s\nMaintainer: %s <%s>\nDescription: COSMIC Desktop Environment meta package\n This meta package installs the complete COSMIC Desktop Environment\n by declaring dependencies on all COSMIC component packages built\n by the cosmic-deb build tool.\n",
 		metaPkg, fv, arch, strings.Join(builtRepos, ", "), maintainerName, maintainerEmail)
 
 	if err := os.WriteFile(filepath.Join(stageDir, "DEBIAN", "control"), []byte(control), 0644); err != nil {
-		return err
+		return "", err
 	}
 	pkgFile := filepath.Join(outDir, fmt.Sprintf("%s_%s_%s.deb", metaPkg, fv, arch))
-	return runDpkg("fakeroot", "dpkg-deb", "--build", stageDir, pkgFile)
+	return pkgFile, runDpkg("fakeroot", "dpkg-deb", "--build", stageDir, pkgFile)
 }
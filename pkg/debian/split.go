@@ -0,0 +1,255 @@
+package debian
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type SplitRule struct {
+	Package          string   `json:"package"`
+	Patterns         []string `json:"patterns"`
+	Depends          []string `json:"depends,omitempty"`
+	Recommends       []string `json:"recommends,omitempty"`
+	Section          string   `json:"section,omitempty"`
+	Description      string   `json:"description,omitempty"`
+	ParentDepends    bool     `json:"parent_depends,omitempty"`
+	ParentRecommends bool     `json:"parent_recommends,omitempty"`
+	DependsOnParent  bool     `json:"depends_on_parent,omitempty"`
+}
+
+type SubPackage struct {
+	Rule     SplitRule
+	StageDir string
+	Files    int
+	Deb      string
+}
+
+//go:embed split.json
+var builtinSplitRules []byte
+
+var SplitRules = mustParseSplitRules(builtinSplitRules)
+
+func mustParseSplitRules(data []byte) map[string][]SplitRule {
+	rules := make(map[string][]SplitRule)
+	if err := json.Unmarshal(data, &rules); err != nil {
+		panic(err)
+	}
+	for parent, list := range rules {
+		if err := CheckSplitRules(list); err != nil {
+			panic(fmt.Sprintf("split rules for %s: %v", parent, err))
+		}
+	}
+	return rules
+}
+
+func CheckSplitRules(rules []SplitRule) error {
+	for i, rule := range rules {
+		if rule.Package == "" || len(rule.Patterns) == 0 {
+			return fmt.Errorf("split rule %d has no package name or patterns", i)
+		}
+	}
+	return nil
+}
+
+func matchPattern(pattern, rel string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pat, segs []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			if len(pat) == 1 {
+				return len(segs) > 0
+			}
+			for i := 0; i <= len(segs); i++ {
+				if matchSegments(pat[1:], segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pat[0], segs[0]); err != nil || !ok {
+			return false
+		}
+		pat, segs = pat[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
+
+func ruleMatches(rule SplitRule, rel string) bool {
+	for _, p := range rule.Patterns {
+		p = strings.Trim(p, "/")
+		for prefix := rel; prefix != "."; prefix = path.Dir(prefix) {
+			if matchPattern(p, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func SplitStage(stageDir, workDir string, rules []SplitRule) ([]SubPackage, error) {
+	var files []string
+	err := filepath.WalkDir(stageDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != stageDir && d.Name() == "DEBIAN" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(stageDir, p)
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckSplitRules(rules); err != nil {
+		return nil, err
+	}
+	subs := make([]SubPackage, len(rules))
+	for i, rule := range rules {
+		subs[i] = SubPackage{Rule: rule, StageDir: filepath.Join(workDir, rule.Package+"-stage")}
+		if err := os.RemoveAll(subs[i].StageDir); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, rel := range files {
+		for i := range subs {
+			if !ruleMatches(subs[i].Rule, rel) {
+				continue
+			}
+			dst := filepath.Join(subs[i].StageDir, filepath.FromSlash(rel))
+			if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+				return nil, err
+			}
+			if err := os.Rename(filepath.Join(stageDir, filepath.FromSlash(rel)), dst); err != nil {
+				return nil, err
+			}
+			subs[i].Files++
+			break
+		}
+	}
+	pruneEmptyDirs(stageDir)
+
+	var produced []SubPackage
+	for _, sub := range subs {
+		if sub.Files > 0 {
+			produced = append(produced, sub)
+		}
+	}
+	return produced, nil
+}
+
+func MergeStage(sub SubPackage, stageDir string) error {
+	err := filepath.WalkDir(sub.StageDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != sub.StageDir && d.Name() == "DEBIAN" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, _ := filepath.Rel(sub.StageDir, p)
+		dst := filepath.Join(stageDir, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+		return os.Rename(p, dst)
+	})
+	if err != nil {
+		return fmt.Errorf("restoring %s files into the parent stage: %v", sub.Rule.Package, err)
+	}
+	return os.RemoveAll(sub.StageDir)
+}
+
+func pruneEmptyDirs(root string) {
+	var dirs []string
+	filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() && p != root {
+			dirs = append(dirs, p)
+		}
+		return nil
+	})
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, d := range dirs {
+		_ = os.Remove(d)
+	}
+}
+
+func SplitDepends(subs []SubPackage, version, distroCodename string) []string {
+	var deps []string
+	for _, sub := range subs {
+		if sub.Rule.ParentDepends {
+			deps = append(deps, fmt.Sprintf("%s (= %s)", sub.Rule.Package, FileVersion(version, distroCodename)))
+		}
+	}
+	return deps
+}
+
+func SplitRecommends(subs []SubPackage) []string {
+	var recs []string
+	for _, sub := range subs {
+		if sub.Rule.ParentRecommends {
+			recs = append(recs, sub.Rule.Package)
+		}
+	}
+	return recs
+}
+
+func BuildSubPackage(sub SubPackage, outDir, parent, version, arch, distroCodename, maintainerName, maintainerEmail string) (string, error) {
+	debianDir := filepath.Join(sub.StageDir, "DEBIAN")
+	if err := os.MkdirAll(debianDir, 0755); err != nil {
+		return "", err
+	}
+	fv := FileVersion(version, distroCodename)
+	rule := sub.Rule
+
+	section := rule.Section
+	if section == "" {
+		section = sectionFor(parent)
+	}
+	description := rule.Description
+	if description == "" {
+		description = "split from " + parent
+	}
+
+	control := fmt.Sprintf("Package: %s\nVersion: %s\nSection: %s\nPriority: optional\nArchitecture: %s\nSource: %s\n",
+		rule.Package, fv, section, arch, parent)
+	depends := rule.Depends
+	if rule.DependsOnParent {
+		depends = append([]string{fmt.Sprintf("%s (= %s)", parent, fv)}, depends...)
+	}
+	if len(depends) > 0 {
+		control += fmt.Sprintf("Depends: %s\n", strings.Join(depends, ", "))
+	}
+	if len(rule.Recommends) > 0 {
+		control += fmt.Sprintf("Recommends: %s\n", strings.Join(rule.Recommends, ", "))
+	}
+	control += fmt.Sprintf("Replaces: %s (<< %s)\nBreaks: %s (<< %s)\n", parent, fv, parent, fv)
+	control += fmt.Sprintf("Maintainer: %s <%s>\nDescription: COSMIC Desktop Environment component — %s\n Built from the %s upstream source via the cosmic-deb build tool.\n",
+		maintainerName, maintainerEmail, description, parent)
+
+	if err := os.WriteFile(filepath.Join(debianDir, "control"), []byte(control), 0644); err != nil {
+		return "", err
+	}
+	pkgFile := filepath.Join(outDir, fmt.Sprintf("%s_%s_%s.deb", rule.Package, fv, arch))
+	return pkgFile, runDpkg("fakeroot", "dpkg-deb", "--build", sub.StageDir, pkgFile)
+}
@@ -0,0 +1,210 @@
+package debian
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBuiltinSplitRules(t *testing.T) {
+	if len(SplitRules) == 0 {
+		t.Fatal("no built-in split rules")
+	}
+	for parent, rules := range SplitRules {
+		if err := CheckSplitRules(rules); err != nil {
+			t.Errorf("%s: %v", parent, err)
+		}
+	}
+}
+
+func TestCheckSplitRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []SplitRule
+		wantErr bool
+	}{
+		{name: "empty", rules: nil},
+		{name: "valid", rules: []SplitRule{{Package: "foo-data", Patterns: []string{"usr/share/foo"}}}},
+		{name: "no package", rules: []SplitRule{{Patterns: []string{"usr/share/foo"}}}, wantErr: true},
+		{name: "no patterns", rules: []SplitRule{{Package: "foo-data"}}, wantErr: true},
+		{
+			name: "second rule invalid",
+			rules: []SplitRule{
+				{Package: "foo-data", Patterns: []string{"usr/share/foo"}},
+				{Package: "foo-doc"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		if err := CheckSplitRules(tt.rules); (err != nil) != tt.wantErr {
+			t.Errorf("%s: CheckSplitRules error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, rel string
+		want         bool
+	}{
+		{"usr/bin/foo", "usr/bin/foo", true},
+		{"usr/bin/foo", "usr/bin/foobar", false},
+		{"usr/bin/foo*", "usr/bin/foobar", true},
+		{"usr/share/icons/hicolor/*/apps/foo*", "usr/share/icons/hicolor/scalable/apps/foo.svg", true},
+		{"usr/share/icons/hicolor/*/apps/foo*", "usr/share/icons/hicolor/48x48/apps/bar.png", false},
+		{"usr/share/**/foo.mo", "usr/share/locale/de/LC_MESSAGES/foo.mo", true},
+		{"usr/share/**/foo.mo", "usr/share/foo.mo", true},
+		{"usr/share/doc/**", "usr/share/doc/foo/README", true},
+		{"usr/share/doc/**", "usr/share/doc", false},
+		{"usr/share/[", "usr/share/[", false},
+	}
+	for _, tt := range tests {
+		if got := matchPattern(tt.pattern, tt.rel); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pattern, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	rule := SplitRule{Package: "foo-data", Patterns: []string{"/usr/share/foo/", "usr/lib/foo/*.so"}}
+	tests := []struct {
+		rel  string
+		want bool
+	}{
+		{"usr/share/foo/themes/dark.ron", true},
+		{"usr/share/foo", true},
+		{"usr/share/foobar/x", false},
+		{"usr/lib/foo/plugin.so", true},
+		{"usr/lib/foo/sub/plugin.so", false},
+		{"usr/bin/foo", false},
+	}
+	for _, tt := range tests {
+		if got := ruleMatches(rule, tt.rel); got != tt.want {
+			t.Errorf("ruleMatches(%q) = %v, want %v", tt.rel, got, tt.want)
+		}
+	}
+}
+
+func writeStage(t *testing.T, root string, files ...string) {
+	t.Helper()
+	for _, f := range files {
+		p := filepath.Join(root, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(f), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func listStage(t *testing.T, root string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestSplitStageAndMerge(t *testing.T) {
+	stage := filepath.Join(t.TempDir(), "stage")
+	work := t.TempDir()
+	writeStage(t, stage,
+		"DEBIAN/control",
+		"usr/bin/foo",
+		"usr/bin/foo-applet",
+		"usr/share/applications/foo-applet.desktop",
+		"usr/share/doc/foo/README",
+	)
+	rules := []SplitRule{
+		{Package: "foo-applet", Patterns: []string{"usr/bin/foo-applet", "usr/share/applications/foo-applet.desktop"}},
+		{Package: "foo-doc", Patterns: []string{"usr/share/doc/**"}},
+		{Package: "foo-doc-dup", Patterns: []string{"usr/share/doc"}},
+		{Package: "foo-unused", Patterns: []string{"usr/lib/**"}},
+	}
+
+	subs, err := SplitStage(stage, work, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range subs {
+		got = append(got, s.Rule.Package)
+	}
+	if want := []string{"foo-applet", "foo-doc"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("produced %v, want %v", got, want)
+	}
+	if subs[0].Files != 2 || subs[1].Files != 1 {
+		t.Errorf("file counts = %d, %d, want 2, 1", subs[0].Files, subs[1].Files)
+	}
+	if want := []string{"DEBIAN/control", "usr/bin/foo"}; !reflect.DeepEqual(listStage(t, stage), want) {
+		t.Errorf("parent stage = %v, want %v", listStage(t, stage), want)
+	}
+	if _, err := os.Stat(filepath.Join(stage, "usr", "share")); !os.IsNotExist(err) {
+		t.Errorf("empty usr/share was not pruned from the parent stage")
+	}
+	if want := []string{"usr/bin/foo-applet", "usr/share/applications/foo-applet.desktop"}; !reflect.DeepEqual(listStage(t, subs[0].StageDir), want) {
+		t.Errorf("foo-applet stage = %v, want %v", listStage(t, subs[0].StageDir), want)
+	}
+
+	for _, s := range subs {
+		if err := MergeStage(s, stage); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(s.StageDir); !os.IsNotExist(err) {
+			t.Errorf("%s stage was not removed after merging", s.Rule.Package)
+		}
+	}
+	want := []string{
+		"DEBIAN/control",
+		"usr/bin/foo",
+		"usr/bin/foo-applet",
+		"usr/share/applications/foo-applet.desktop",
+		"usr/share/doc/foo/README",
+	}
+	if got := listStage(t, stage); !reflect.DeepEqual(got, want) {
+		t.Errorf("merged stage = %v, want %v", got, want)
+	}
+}
+
+func TestSplitStageRejectsInvalidRules(t *testing.T) {
+	stage := t.TempDir()
+	writeStage(t, stage, "usr/bin/foo")
+	if _, err := SplitStage(stage, t.TempDir(), []SplitRule{{Package: "foo-data"}}); err == nil {
+		t.Error("SplitStage accepted a rule without patterns")
+	}
+}
+
+func TestSplitRelations(t *testing.T) {
+	subs := []SubPackage{
+		{Rule: SplitRule{Package: "foo-applet", ParentRecommends: true}},
+		{Rule: SplitRule{Package: "foo-data", ParentDepends: true}},
+		{Rule: SplitRule{Package: "foo-doc"}},
+	}
+	tests := []struct {
+		codename string
+		want     []string
+	}{
+		{"", []string{"foo-data (= 1.0.0)"}},
+		{"noble", []string{"foo-data (= 1.0.0~noble)"}},
+	}
+	for _, tt := range tests {
+		if got := SplitDepends(subs, "1.0.0", tt.codename); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitDepends(%q) = %v, want %v", tt.codename, got, tt.want)
+		}
+	}
+	if got, want := SplitRecommends(subs), []string{"foo-applet"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitRecommends = %v, want %v", got, want)
+	}
+}
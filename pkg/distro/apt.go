@@ -0,0 +1,122 @@
+package distro
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+type AptCandidate struct {
+	Installed string
+	Candidate string
+}
+
+func (c AptCandidate) Version() string {
+	if c.Candidate != "" && c.Candidate != "(none)" {
+		return c.Candidate
+	}
+	if c.Installed != "" && c.Installed != "(none)" {
+		return c.Installed
+	}
+	return ""
+}
+
+func (c AptCandidate) Available() bool {
+	return c.Version() != ""
+}
+
+var aptPolicyCache = make(map[string]AptCandidate)
+
+func AptMetadataAvailable() bool {
+	if _, err := exec.LookPath("apt-cache"); err != nil {
+		return false
+	}
+	lists, _ := filepath.Glob("/var/lib/apt/lists/*_Packages*")
+	return len(lists) > 0
+}
+
+func AptPolicy(pkgs ...string) map[string]AptCandidate {
+	var query []string
+	for _, p := range pkgs {
+		if _, ok := aptPolicyCache[p]; !ok {
+			query = append(query, p)
+		}
+	}
+	if len(query) > 0 {
+		for _, p := range query {
+			aptPolicyCache[p] = AptCandidate{}
+		}
+		out, _ := exec.Command("apt-cache", append([]string{"policy"}, query...)...).Output()
+		var cur string
+		for _, line := range strings.Split(string(out), "\n") {
+			if line == "" {
+				continue
+			}
+			if !strings.HasPrefix(line, " ") && strings.HasSuffix(line, ":") {
+				cur = strings.TrimSuffix(line, ":")
+				continue
+			}
+			if cur == "" {
+				continue
+			}
+			key, val, ok := strings.Cut(strings.TrimSpace(line), ":")
+			if !ok {
+				continue
+			}
+			c := aptPolicyCache[cur]
+			switch key {
+			case "Installed":
+				c.Installed = strings.TrimSpace(val)
+			case "Candidate":
+				c.Candidate = strings.TrimSpace(val)
+			}
+			aptPolicyCache[cur] = c
+		}
+	}
+	result := make(map[string]AptCandidate, len(pkgs))
+	for _, p := range pkgs {
+		result[p] = aptPolicyCache[p]
+	}
+	return result
+}
+
+func VersionAtLeast(version, minimum string) bool {
+	if minimum == "" {
+		return true
+	}
+	return CompareVersions(version, "ge", minimum)
+}
+
+func CompareVersions(a, op, b string) bool {
+	return exec.Command("dpkg", "--compare-versions", a, op, b).Run() == nil
+}
+
+type AptProvider struct {
+	Package         string
+	ProvidedVersion string
+}
+
+func AptProviders(virtual string) []AptProvider {
+	out, err := exec.Command("apt-cache", "showpkg", virtual).Output()
+	if err != nil {
+		return nil
+	}
+	var providers []AptProvider
+	inProvides := false
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "Reverse Provides:") {
+			inProvides = true
+			continue
+		}
+		if !inProvides || strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		p := AptProvider{Package: fields[0]}
+		if len(fields) >= 4 && fields[2] == "(=" {
+			p.ProvidedVersion = strings.TrimSuffix(fields[3], ")")
+		}
+		providers = append(providers, p)
+	}
+	return providers
+}
@@ -0,0 +1,116 @@
+package distro
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+//go:embed capabilities.json
+var builtinCapabilities []byte
+
+type Alternative struct {
+	Package    string `json:"package"`
+	MinVersion string `json:"min_version,omitempty"`
+}
+
+type CapabilityMatrix struct {
+	Packages map[string][]Alternative `json:"packages"`
+}
+
+var capabilities = mustParseCapabilities(builtinCapabilities)
+
+func mustParseCapabilities(data []byte) CapabilityMatrix {
+	m, err := parseCapabilities(data)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func parseCapabilities(data []byte) (CapabilityMatrix, error) {
+	var m CapabilityMatrix
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, err
+	}
+	for name, alts := range m.Packages {
+		if len(alts) == 0 {
+			return m, fmt.Errorf("capability '%s' lists no alternatives", name)
+		}
+		for _, a := range alts {
+			if a.Package == "" {
+				return m, fmt.Errorf("capability '%s' has an alternative without a package name", name)
+			}
+		}
+	}
+	return m, nil
+}
+
+func LoadCapabilityFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	m, err := parseCapabilities(data)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	for name, alts := range m.Packages {
+		capabilities.Packages[name] = alts
+	}
+	return nil
+}
+
+func ResolvePackage(name string) (resolved string, available bool, known bool) {
+	if !AptMetadataAvailable() {
+		return name, false, false
+	}
+	alts, ok := capabilities.Packages[name]
+	if !ok {
+		alts = []Alternative{{Package: name}}
+	}
+	var names []string
+	for _, a := range alts {
+		names = append(names, a.Package)
+	}
+	policy := AptPolicy(names...)
+	for _, a := range alts {
+		c := policy[a.Package]
+		if !c.Available() {
+			continue
+		}
+		if !VersionAtLeast(c.Version(), a.MinVersion) {
+			continue
+		}
+		return a.Package, true, true
+	}
+	return name, false, true
+}
+
+func ResolveAlternatives(deps []string) (resolved []string, unavailable []string) {
+	if !AptMetadataAvailable() {
+		return deps, nil
+	}
+	var all []string
+	for _, dep := range deps {
+		all = append(all, dep)
+		for _, a := range capabilities.Packages[dep] {
+			all = append(all, a.Package)
+		}
+	}
+	AptPolicy(all...)
+	seen := make(map[string]bool)
+	for _, dep := range deps {
+		pkg, ok, _ := ResolvePackage(dep)
+		if !ok {
+			unavailable = append(unavailable, dep)
+			continue
+		}
+		if !seen[pkg] {
+			seen[pkg] = true
+			resolved = append(resolved, pkg)
+		}
+	}
+	return resolved, unavailable
+}
@@ -0,0 +1,277 @@
+package distro
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Relation struct {
+	Name      string
+	Qualifier string
+	Op        string
+	Version   string
+	Archs     []string
+	Profiles  string
+}
+
+type RelationGroup []Relation
+
+func (r Relation) String() string {
+	s := r.Name
+	if r.Qualifier != "" {
+		s += ":" + r.Qualifier
+	}
+	if r.Op != "" {
+		s += fmt.Sprintf(" (%s %s)", r.Op, r.Version)
+	}
+	if len(r.Archs) > 0 {
+		s += " [" + strings.Join(r.Archs, " ") + "]"
+	}
+	if r.Profiles != "" {
+		s += " " + r.Profiles
+	}
+	return s
+}
+
+func (g RelationGroup) String() string {
+	var parts []string
+	for _, r := range g {
+		parts = append(parts, r.String())
+	}
+	return strings.Join(parts, " | ")
+}
+
+func BuildDepsFromControl(control, hostArch string) ([]string, []RelationGroup, error) {
+	groups, err := ParseControlBuildDepends(control)
+	if err != nil {
+		return nil, nil, err
+	}
+	pkgs, unresolved := ResolveBuildDepends(groups, hostArch)
+	return pkgs, unresolved, nil
+}
+
+func ParseControlBuildDepends(control string) ([]RelationGroup, error) {
+	fields := make(map[string]string)
+	var current string
+	for _, line := range strings.Split(control, "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			if len(fields) > 0 {
+				break
+			}
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			if current != "" {
+				fields[current] += " " + strings.TrimSpace(line)
+			}
+			continue
+		}
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("malformed control line %q", line)
+		}
+		current = strings.ToLower(strings.TrimSpace(key))
+		fields[current] = strings.TrimSpace(val)
+	}
+	if _, ok := fields["source"]; !ok {
+		return nil, fmt.Errorf("control file has no Source paragraph")
+	}
+	var groups []RelationGroup
+	for _, key := range []string{"build-depends", "build-depends-arch", "build-depends-indep"} {
+		for _, raw := range strings.Split(fields[key], ",") {
+			raw = strings.TrimSpace(raw)
+			if raw == "" {
+				continue
+			}
+			var group RelationGroup
+			for _, alt := range strings.Split(raw, "|") {
+				rel, err := parseRelation(strings.TrimSpace(alt))
+				if err != nil {
+					return nil, err
+				}
+				if strings.HasPrefix(rel.Name, "${") {
+					continue
+				}
+				group = append(group, rel)
+			}
+			if len(group) > 0 {
+				groups = append(groups, group)
+			}
+		}
+	}
+	return groups, nil
+}
+
+func parseRelation(s string) (Relation, error) {
+	var r Relation
+	depth := 0
+	for i, ch := range s {
+		switch ch {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '<':
+			if depth == 0 {
+				r.Profiles = strings.TrimSpace(s[i:])
+				s = strings.TrimSpace(s[:i])
+			}
+		}
+		if r.Profiles != "" {
+			break
+		}
+	}
+	if open := strings.Index(s, "["); open >= 0 {
+		end := strings.Index(s, "]")
+		if end < open {
+			return r, fmt.Errorf("unterminated architecture list in %q", s)
+		}
+		r.Archs = strings.Fields(s[open+1 : end])
+		s = strings.TrimSpace(s[:open] + s[end+1:])
+	}
+	if open := strings.Index(s, "("); open >= 0 {
+		end := strings.Index(s, ")")
+		if end < open {
+			return r, fmt.Errorf("unterminated version constraint in %q", s)
+		}
+		constraint := strings.TrimSpace(s[open+1 : end])
+		s = strings.TrimSpace(s[:open])
+		for _, op := range []string{">=", "<=", ">>", "<<", "="} {
+			if strings.HasPrefix(constraint, op) {
+				r.Op = op
+				r.Version = strings.TrimSpace(strings.TrimPrefix(constraint, op))
+				break
+			}
+		}
+		if r.Op == "" {
+			return r, fmt.Errorf("unsupported version constraint %q", constraint)
+		}
+	}
+	name := strings.TrimSpace(s)
+	if n, q, ok := strings.Cut(name, ":"); ok {
+		name, r.Qualifier = n, q
+	}
+	if name == "" {
+		return r, fmt.Errorf("empty package name in relation")
+	}
+	r.Name = name
+	return r, nil
+}
+
+func (r Relation) appliesTo(hostArch string) bool {
+	if len(r.Archs) > 0 {
+		negated := strings.HasPrefix(r.Archs[0], "!")
+		matched := false
+		for _, a := range r.Archs {
+			a = strings.TrimPrefix(a, "!")
+			if a == hostArch || a == "any" || a == "linux-any" || a == "linux-"+hostArch || a == "any-"+hostArch {
+				matched = true
+			}
+		}
+		if negated == matched {
+			return false
+		}
+	}
+	if r.Profiles != "" {
+		for _, formula := range strings.Split(r.Profiles, ">") {
+			formula = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(formula), "<"))
+			if formula == "" {
+				continue
+			}
+			satisfied := true
+			for _, term := range strings.Fields(formula) {
+				if !strings.HasPrefix(term, "!") {
+					satisfied = false
+				}
+			}
+			if satisfied {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+var debianOps = map[string]string{">=": "ge", "<=": "le", ">>": "gt", "<<": "lt", "=": "eq"}
+
+func (r Relation) satisfiedBy(version string) bool {
+	if r.Op == "" {
+		return true
+	}
+	return CompareVersions(version, debianOps[r.Op], r.Version)
+}
+
+func resolveProvider(group RelationGroup) string {
+	for _, r := range group {
+		for _, p := range AptProviders(r.Name) {
+			if r.Op != "" && (p.ProvidedVersion == "" || !r.satisfiedBy(p.ProvidedVersion)) {
+				continue
+			}
+			return p.Package
+		}
+	}
+	return ""
+}
+
+func ResolveBuildDepends(groups []RelationGroup, hostArch string) (pkgs []string, unresolved []RelationGroup) {
+	var names []string
+	for _, g := range groups {
+		for _, r := range g {
+			names = append(names, r.Name)
+		}
+	}
+	aptKnown := AptMetadataAvailable()
+	var policy map[string]AptCandidate
+	if aptKnown {
+		policy = AptPolicy(names...)
+	}
+	seen := make(map[string]bool)
+	for _, g := range groups {
+		var applicable RelationGroup
+		for _, r := range g {
+			if r.appliesTo(hostArch) {
+				applicable = append(applicable, r)
+			}
+		}
+		if len(applicable) == 0 {
+			continue
+		}
+		chosen := ""
+		if !aptKnown {
+			chosen = applicable[0].Name
+		}
+		for _, r := range applicable {
+			if chosen != "" {
+				break
+			}
+			c := policy[r.Name]
+			if c.Installed != "" && c.Installed != "(none)" && r.satisfiedBy(c.Installed) {
+				chosen = r.Name
+			}
+		}
+		for _, r := range applicable {
+			if chosen != "" {
+				break
+			}
+			if v := policy[r.Name].Version(); v != "" && r.satisfiedBy(v) {
+				chosen = r.Name
+			}
+		}
+		if chosen == "" && aptKnown {
+			chosen = resolveProvider(applicable)
+		}
+		if chosen == "" {
+			unresolved = append(unresolved, applicable)
+			continue
+		}
+		if !seen[chosen] {
+			seen[chosen] = true
+			pkgs = append(pkgs, chosen)
+		}
+	}
+	return pkgs, unresolved
+}
@@ -0,0 +1,114 @@
+package distro
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testControl = `# comment
+Source: cosmic-foo
+Section: x11
+Build-Depends: debhelper-compat (= 13),
+ cargo:native (>= 0.66),
+ libwayland-dev [linux-any],
+ libudev-dev [!hurd-i386 !kfreebsd-amd64],
+ libseat-dev | libsystemd-dev (>> 250),
+ ${misc:Depends},
+ dh-cargo <!nocheck>,
+Build-Depends-Arch: libdisplay-info-dev [amd64 arm64]
+Build-Depends-Indep: python3-sphinx <!nodoc> <cross>
+
+Package: cosmic-foo
+Architecture: any
+Depends: libc6
+`
+
+func TestParseControlBuildDepends(t *testing.T) {
+	groups, err := ParseControlBuildDepends(testControl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RelationGroup{
+		{{Name: "debhelper-compat", Op: "=", Version: "13"}},
+		{{Name: "cargo", Qualifier: "native", Op: ">=", Version: "0.66"}},
+		{{Name: "libwayland-dev", Archs: []string{"linux-any"}}},
+		{{Name: "libudev-dev", Archs: []string{"!hurd-i386", "!kfreebsd-amd64"}}},
+		{{Name: "libseat-dev"}, {Name: "libsystemd-dev", Op: ">>", Version: "250"}},
+		{{Name: "dh-cargo", Profiles: "<!nocheck>"}},
+		{{Name: "libdisplay-info-dev", Archs: []string{"amd64", "arm64"}}},
+		{{Name: "python3-sphinx", Profiles: "<!nodoc> <cross>"}},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Errorf("ParseControlBuildDepends =\n%+v\nwant\n%+v", groups, want)
+	}
+}
+
+func TestParseControlBuildDependsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		control string
+	}{
+		{"no source paragraph", "Package: foo\nArchitecture: any\n"},
+		{"malformed line", "Source: foo\nBuild-Depends foo\n"},
+		{"unterminated arch list", "Source: foo\nBuild-Depends: bar ]amd64[\n"},
+		{"unterminated version", "Source: foo\nBuild-Depends: bar )1.0(\n"},
+		{"unsupported operator", "Source: foo\nBuild-Depends: bar (~ 1.0)\n"},
+		{"empty name", "Source: foo\nBuild-Depends: (>= 1.0)\n"},
+	}
+	for _, tt := range tests {
+		if _, err := ParseControlBuildDepends(tt.control); err == nil {
+			t.Errorf("%s: ParseControlBuildDepends succeeded, want error", tt.name)
+		}
+	}
+}
+
+func TestRelationString(t *testing.T) {
+	tests := []struct {
+		group RelationGroup
+		want  string
+	}{
+		{RelationGroup{{Name: "foo"}}, "foo"},
+		{RelationGroup{{Name: "cargo", Qualifier: "native", Op: ">=", Version: "0.66"}}, "cargo:native (>= 0.66)"},
+		{RelationGroup{{Name: "foo", Archs: []string{"!s390x"}, Profiles: "<!nocheck>"}}, "foo [!s390x] <!nocheck>"},
+		{RelationGroup{{Name: "a"}, {Name: "b", Op: "<<", Version: "2"}}, "a | b (<< 2)"},
+	}
+	for _, tt := range tests {
+		if got := tt.group.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestRelationAppliesTo(t *testing.T) {
+	tests := []struct {
+		relation string
+		hostArch string
+		want     bool
+	}{
+		{"foo", "amd64", true},
+		{"foo [amd64 arm64]", "amd64", true},
+		{"foo [amd64 arm64]", "riscv64", false},
+		{"foo [!amd64]", "amd64", false},
+		{"foo [!amd64]", "arm64", true},
+		{"foo [!hurd-i386 !kfreebsd-amd64]", "amd64", true},
+		{"foo [linux-any]", "ppc64el", true},
+		{"foo [linux-arm64]", "arm64", true},
+		{"foo [any-arm64]", "amd64", false},
+		{"foo <!nocheck>", "amd64", true},
+		{"foo <nocheck>", "amd64", false},
+		{"foo <!nocheck !nodoc>", "amd64", true},
+		{"foo <cross> <!nocheck>", "amd64", true},
+		{"foo <cross !nocheck>", "amd64", false},
+		{"foo [amd64] <!nocheck>", "amd64", true},
+		{"foo [arm64] <!nocheck>", "amd64", false},
+	}
+	for _, tt := range tests {
+		r, err := parseRelation(tt.relation)
+		if err != nil {
+			t.Fatalf("parseRelation(%q): %v", tt.relation, err)
+		}
+		if got := r.appliesTo(tt.hostArch); got != tt.want {
+			t.Errorf("%q appliesTo(%s) = %v, want %v", tt.relation, tt.hostArch, got, tt.want)
+		}
+	}
+}
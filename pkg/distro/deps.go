@@ -1,6 +1,15 @@
 package distro
 
+import "sort"
+
 func HasDisplayInfoDev(id, codename string) bool {
+	if _, ok, known := ResolvePackage("libdisplay-info-dev"); known {
+		return ok
+	}
+	return staticDisplayInfoDev(id, codename)
+}
+
+func staticDisplayInfoDev(id, codename string) bool {
 	switch id {
 	case "debian":
 		switch codename {
@@ -17,6 +26,13 @@ func HasDisplayInfoDev(id, codename string) bool {
 }
 
 func HasRustAll(id, codename string) bool {
+	if _, ok, known := ResolvePackage("rust-all"); known {
+		return ok
+	}
+	return staticRustAll(id, codename)
+}
+
+func staticRustAll(id, codename string) bool {
 	switch id {
 	case "debian":
 		return true
@@ -30,6 +46,13 @@ func HasRustAll(id, codename string) bool {
 }
 
 func HasDhCargo(id, codename string) bool {
+	if _, ok, known := ResolvePackage("dh-cargo"); known {
+		return ok
+	}
+	return staticDhCargo(id, codename)
+}
+
+func staticDhCargo(id, codename string) bool {
 	switch id {
 	case "debian":
 		return true
@@ -43,6 +66,13 @@ func HasDhCargo(id, codename string) bool {
 }
 
 func HasJustInApt(id, codename string) bool {
+	if _, ok, known := ResolvePackage("just"); known {
+		return ok
+	}
+	return staticJustInApt(id, codename)
+}
+
+func staticJustInApt(id, codename string) bool {
 	if id == "debian" {
 		switch codename {
 		case "trixie", "forky", "sid", "unstable", "testing":
@@ -55,23 +85,16 @@ func HasJustInApt(id, codename string) bool {
 func GlobalBuildDeps(id, codename string) []string {
 	deps := []string{
 		"build-essential", "cargo", "clang", "cmake", "curl",
-		"debhelper", "desktop-file-utils", "devscripts", "git",
-		"imagemagick", "intltool", "iso-codes", "libclang-dev",
-		"libdbus-1-dev", "libegl-dev", "libegl1-mesa-dev",
-		"libexpat1-dev", "libflatpak-dev", "libfontconfig-dev",
-		"libfreetype-dev", "libgbm-dev", "libglib2.0-dev",
-		"libgstreamer-plugins-base1.0-dev", "libgstreamer1.0-dev",
-		"libinput-dev", "libnm-dev", "libpam0g-dev",
-		"libpipewire-0.3-dev", "libpixman-1-dev", "libpulse-dev",
-		"libseat-dev", "libssl-dev", "libsystemd-dev", "libudev-dev",
-		"libwayland-dev", "libxcb-render0-dev", "libxcb-shape0-dev",
-		"libxcb-xfixes0-dev", "libxcb1-dev", "libxkbcommon-dev",
-		"lld", "mold", "nasm", "pkg-config", "rustc", "fakeroot",
-		"ninja-build", "meson", "sassc", "quilt",
-		"libfile-fcntllock-perl", "dh-make", "dpkg-dev",
+		"debhelper", "devscripts", "git", "libclang-dev",
+		"libexpat1-dev", "libegl-dev", "libfontconfig-dev",
+		"libfreetype-dev", "libwayland-dev", "libxkbcommon-dev",
+		"lld", "mold", "pkg-config", "rustc", "fakeroot", "quilt",
+		"libfile-fcntllock-perl", "dh-make", "dpkg-dev", "dh-exec",
+		"iso-codes", "libxcb-render0-dev", "libxcb-shape0-dev",
+		"libxcb-xfixes0-dev", "ninja-build", "meson", "sassc",
 		"libglib2.0-dev-bin", "libwayland-bin", "libxml2-utils",
 		"libglib2.0-bin", "gettext", "itstool", "wayland-protocols",
-		"libgdk-pixbuf-2.0-dev", "dh-exec",
+		"libgdk-pixbuf-2.0-dev",
 	}
 	if HasDisplayInfoDev(id, codename) {
 		deps = append(deps, "libdisplay-info-dev")
@@ -106,7 +129,8 @@ func PerComponentBuildDeps(id, codename string) map[string][]string {
 		"debhelper", "cmake", "just", "libclang-dev",
 		"libexpat1-dev", "libfontconfig-dev", "libfreetype-dev",
 		"libinput-dev", "libpipewire-0.3-dev", "libudev-dev",
-		"libwayland-dev", "libxkbcommon-dev", "mold", "pkg-config",
+		"libnm-dev", "libwayland-dev", "libxkbcommon-dev", "mold",
+		"pkg-config",
 	}
 	if displayInfo {
 		cosmicSettings = append(cosmicSettings, "libdisplay-info-dev")
@@ -220,7 +244,18 @@ func PerComponentBuildDeps(id, codename string) map[string][]string {
 	}
 }
 
+const GlobalComponent = "(global)"
+
 func CollectAllBuildDeps(id, codename string) []string {
+	perComponent := PerComponentBuildDeps(id, codename)
+	var components []string
+	for name := range perComponent {
+		components = append(components, name)
+	}
+	return ScopedBuildDeps(id, codename, perComponent, components)
+}
+
+func ScopedBuildDeps(id, codename string, perComponent map[string][]string, components []string) []string {
 	seen := make(map[string]bool)
 	var result []string
 	for _, dep := range GlobalBuildDeps(id, codename) {
@@ -229,8 +264,10 @@ func CollectAllBuildDeps(id, codename string) []string {
 			result = append(result, dep)
 		}
 	}
-	for _, deps := range PerComponentBuildDeps(id, codename) {
-		for _, dep := range deps {
+	sorted := append([]string(nil), components...)
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		for _, dep := range perComponent[name] {
 			if !seen[dep] {
 				seen[dep] = true
 				result = append(result, dep)
@@ -239,3 +276,21 @@ func CollectAllBuildDeps(id, codename string) []string {
 	}
 	return result
 }
+
+func ExplainBuildDeps(id, codename string, perComponent map[string][]string, components []string) map[string][]string {
+	owners := make(map[string][]string)
+	for _, dep := range GlobalBuildDeps(id, codename) {
+		owners[dep] = []string{GlobalComponent}
+	}
+	sorted := append([]string(nil), components...)
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		for _, dep := range perComponent[name] {
+			if len(owners[dep]) > 0 && owners[dep][len(owners[dep])-1] == name {
+				continue
+			}
+			owners[dep] = append(owners[dep], name)
+		}
+	}
+	return owners
+}
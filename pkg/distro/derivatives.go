@@ -0,0 +1,78 @@
+package distro
+
+import "strings"
+
+type Base struct {
+	ID       string
+	Codename string
+}
+
+var derivativeCodenames = map[string]map[string]Base{
+	"linuxmint": {
+		"vanessa":  {"ubuntu", "jammy"},
+		"vera":     {"ubuntu", "jammy"},
+		"victoria": {"ubuntu", "jammy"},
+		"virginia": {"ubuntu", "jammy"},
+		"wilma":    {"ubuntu", "noble"},
+		"xia":      {"ubuntu", "noble"},
+		"faye":     {"debian", "bookworm"},
+		"gigi":     {"debian", "trixie"},
+	},
+	"elementary": {
+		"horus": {"ubuntu", "jammy"},
+		"circe": {"ubuntu", "noble"},
+	},
+	"zorin": {
+		"jammy": {"ubuntu", "jammy"},
+		"noble": {"ubuntu", "noble"},
+	},
+	"pop": {
+		"jammy": {"ubuntu", "jammy"},
+		"noble": {"ubuntu", "noble"},
+	},
+	"neon": {
+		"jammy": {"ubuntu", "jammy"},
+		"noble": {"ubuntu", "noble"},
+	},
+	"kali": {
+		"kali-rolling": {"debian", "testing"},
+	},
+	"parrot": {
+		"lory": {"debian", "bookworm"},
+	},
+	"devuan": {
+		"daedalus":  {"debian", "bookworm"},
+		"excalibur": {"debian", "trixie"},
+		"freia":     {"debian", "forky"},
+		"ceres":     {"debian", "sid"},
+	},
+	"pureos": {
+		"byzantium": {"debian", "bullseye"},
+		"crimson":   {"debian", "bookworm"},
+	},
+	"raspbian": {
+		"bookworm": {"debian", "bookworm"},
+		"trixie":   {"debian", "trixie"},
+	},
+}
+
+func resolveBase(vals map[string]string, id, codename string) (Base, bool) {
+	if id == "debian" || id == "ubuntu" {
+		return Base{id, codename}, false
+	}
+	if c := vals["UBUNTU_CODENAME"]; c != "" {
+		return Base{"ubuntu", c}, true
+	}
+	if c := vals["DEBIAN_CODENAME"]; c != "" {
+		return Base{"debian", c}, true
+	}
+	if b, ok := derivativeCodenames[id][codename]; ok {
+		return b, true
+	}
+	for _, like := range strings.Fields(vals["ID_LIKE"]) {
+		if like == "ubuntu" || like == "debian" {
+			return Base{like, codename}, true
+		}
+	}
+	return Base{id, codename}, false
+}
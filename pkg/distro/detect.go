@@ -7,8 +7,14 @@ import (
 )
 
 type Info struct {
-	ID       string
-	Codename string
+	ID              string
+	Codename        string
+	ReleaseID       string
+	ReleaseCodename string
+}
+
+func (i Info) Derived() bool {
+	return i.ReleaseID != i.ID || i.ReleaseCodename != i.Codename
 }
 
 func Detect() Info {
@@ -27,6 +33,10 @@ func Detect() Info {
 		if info.Codename == "" {
 			info.Codename = vals["VERSION_ID"]
 		}
+		info.ReleaseID, info.ReleaseCodename = info.ID, info.Codename
+		if base, ok := resolveBase(vals, info.ID, info.Codename); ok {
+			info.ID, info.Codename = base.ID, base.Codename
+		}
 	}
 	if info.ID == "" || info.ID == "unknown" {
 		if IsAptBased() {
@@ -38,6 +48,12 @@ func Detect() Info {
 			info.Codename = "sid"
 		}
 	}
+	if info.ReleaseID == "" {
+		info.ReleaseID = info.ID
+	}
+	if info.ReleaseCodename == "" {
+		info.ReleaseCodename = info.Codename
+	}
 	return info
 }
 
@@ -62,18 +78,3 @@ func CheckSupported(id, codename string) (bool, string) {
 	}
 	return false, "Distribution detection failed or unsupported. This program requires an APT-based system with 'dpkg' (Debian/Ubuntu style)."
 }
-
-func IsContainer() bool {
-	if _, err := os.Stat("/.dockerenv"); err == nil {
-		return true
-	}
-	data, _ := os.ReadFile("/proc/1/cgroup")
-	if strings.Contains(string(data), "docker") || strings.Contains(string(data), "lxc") || strings.Contains(string(data), "containerd") {
-		return true
-	}
-	data2, _ := os.ReadFile("/proc/self/mountinfo")
-	if strings.Contains(string(data2), "/docker/") {
-		return true
-	}
-	return false
-}
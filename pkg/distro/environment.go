@@ -0,0 +1,266 @@
+package distro
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+type Environment struct {
+	Container  string
+	Hypervisor string
+	WSL        int
+	Chroot     bool
+	CgroupV2   bool
+	CPULimit   int
+}
+
+func (e Environment) InContainer() bool {
+	return e.Container != ""
+}
+
+func (e Environment) Virtualized() bool {
+	return e.Hypervisor != "" || e.WSL > 0
+}
+
+func (e Environment) String() string {
+	var parts []string
+	if e.Container != "" {
+		parts = append(parts, "container="+e.Container)
+	}
+	if e.WSL > 0 {
+		parts = append(parts, fmt.Sprintf("wsl=%d", e.WSL))
+	}
+	if e.Hypervisor != "" {
+		parts = append(parts, "vm="+e.Hypervisor)
+	}
+	if e.Chroot {
+		parts = append(parts, "chroot")
+	}
+	if e.CgroupV2 {
+		parts = append(parts, "cgroup=v2")
+	} else {
+		parts = append(parts, "cgroup=v1")
+	}
+	if e.CPULimit > 0 {
+		parts = append(parts, fmt.Sprintf("cpu-limit=%d", e.CPULimit))
+	}
+	if e.Container == "" && e.WSL == 0 && e.Hypervisor == "" && !e.Chroot {
+		parts = append([]string{"bare-metal"}, parts...)
+	}
+	return strings.Join(parts, " ")
+}
+
+var detectedEnv *Environment
+
+func DetectEnvironment() Environment {
+	if detectedEnv != nil {
+		return *detectedEnv
+	}
+	env := Environment{
+		Container:  detectContainer(),
+		Hypervisor: detectHypervisor(),
+		WSL:        detectWSL(),
+		Chroot:     detectChroot(),
+	}
+	if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err == nil {
+		env.CgroupV2 = true
+	}
+	env.CPULimit = cgroupCPULimit(env.CgroupV2)
+	if env.WSL > 0 && env.Hypervisor == "microsoft" {
+		env.Hypervisor = ""
+	}
+	detectedEnv = &env
+	return env
+}
+
+func IsContainer() bool {
+	return DetectEnvironment().InContainer()
+}
+
+func detectVirt(flag string) string {
+	out, err := exec.Command("systemd-detect-virt", flag).Output()
+	if err != nil {
+		return ""
+	}
+	v := strings.TrimSpace(string(out))
+	if v == "none" {
+		return ""
+	}
+	return v
+}
+
+func detectContainer() string {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return "kubernetes"
+	}
+	if _, err := os.Stat("/var/run/secrets/kubernetes.io/serviceaccount"); err == nil {
+		return "kubernetes"
+	}
+	if data, err := os.ReadFile("/run/.containerenv"); err == nil {
+		if strings.Contains(string(data), "engine=\"buildah") {
+			return "buildah"
+		}
+		return "podman"
+	}
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		return "docker"
+	}
+	if data, err := os.ReadFile("/run/systemd/container"); err == nil {
+		if v := strings.TrimSpace(string(data)); v != "" {
+			return v
+		}
+	}
+	if data, err := os.ReadFile("/proc/1/environ"); err == nil {
+		for _, kv := range strings.Split(string(data), "\x00") {
+			if v, ok := strings.CutPrefix(kv, "container="); ok && v != "" {
+				return v
+			}
+		}
+	}
+	if v := os.Getenv("container"); v != "" {
+		return v
+	}
+	if v := detectVirt("--container"); v != "" {
+		return v
+	}
+	cgroup, _ := os.ReadFile("/proc/1/cgroup")
+	for _, marker := range []struct{ substr, name string }{
+		{"kubepods", "kubernetes"},
+		{"libpod", "podman"},
+		{"docker", "docker"},
+		{"lxc", "lxc"},
+		{"containerd", "containerd"},
+		{"machine.slice/systemd-nspawn", "systemd-nspawn"},
+	} {
+		if strings.Contains(string(cgroup), marker.substr) {
+			return marker.name
+		}
+	}
+	root := rootMountEntry()
+	switch {
+	case strings.Contains(root, "/docker/"):
+		return "docker"
+	case strings.Contains(root, "/containers/storage/overlay"):
+		return "podman"
+	case strings.Contains(root, "/kubelet/pods/"):
+		return "kubernetes"
+	}
+	return ""
+}
+
+func rootMountEntry() string {
+	mountinfo, _ := os.ReadFile("/proc/self/mountinfo")
+	root := ""
+	for _, line := range strings.Split(string(mountinfo), "\n") {
+		if fields := strings.Fields(line); len(fields) > 4 && fields[4] == "/" {
+			root = line
+		}
+	}
+	return root
+}
+
+func detectWSL() int {
+	release, _ := os.ReadFile("/proc/sys/kernel/osrelease")
+	r := strings.ToLower(string(release))
+	if strings.Contains(r, "wsl2") {
+		return 2
+	}
+	if strings.Contains(r, "microsoft") {
+		return 1
+	}
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		if _, err := os.Stat("/run/WSL"); err == nil {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+var dmiHypervisors = []struct{ substr, name string }{
+	{"qemu", "qemu"},
+	{"kvm", "kvm"},
+	{"vmware", "vmware"},
+	{"virtualbox", "oracle"},
+	{"innotek", "oracle"},
+	{"xen", "xen"},
+	{"parallels", "parallels"},
+	{"bochs", "bochs"},
+	{"amazon ec2", "amazon"},
+	{"google compute engine", "google"},
+	{"microsoft corporation virtual machine", "microsoft"},
+}
+
+func detectHypervisor() string {
+	if v := detectVirt("--vm"); v != "" {
+		return v
+	}
+	var dmi []string
+	for _, f := range []string{"sys_vendor", "product_name", "bios_vendor", "board_vendor"} {
+		if data, err := os.ReadFile("/sys/class/dmi/id/" + f); err == nil {
+			dmi = append(dmi, strings.TrimSpace(string(data)))
+		}
+	}
+	joined := strings.ToLower(strings.Join(dmi, " "))
+	for _, h := range dmiHypervisors {
+		if strings.Contains(joined, h.substr) {
+			return h.name
+		}
+	}
+	if data, err := os.ReadFile("/sys/hypervisor/type"); err == nil {
+		if v := strings.TrimSpace(string(data)); v != "" {
+			return v
+		}
+	}
+	cpuinfo, _ := os.ReadFile("/proc/cpuinfo")
+	for _, line := range strings.Split(string(cpuinfo), "\n") {
+		if strings.HasPrefix(line, "flags") && strings.Contains(line, " hypervisor") {
+			return "unknown"
+		}
+	}
+	return ""
+}
+
+func detectChroot() bool {
+	if exec.Command("ischroot").Run() == nil {
+		return true
+	}
+	var root, init syscall.Stat_t
+	if syscall.Stat("/", &root) != nil || syscall.Stat("/proc/1/root/.", &init) != nil {
+		return false
+	}
+	return root.Dev != init.Dev || root.Ino != init.Ino
+}
+
+func cgroupCPULimit(v2 bool) int {
+	var quota, period float64
+	if v2 {
+		data, err := os.ReadFile("/sys/fs/cgroup/cpu.max")
+		if err != nil {
+			return 0
+		}
+		fields := strings.Fields(string(data))
+		if len(fields) != 2 || fields[0] == "max" {
+			return 0
+		}
+		quota, _ = strconv.ParseFloat(fields[0], 64)
+		period, _ = strconv.ParseFloat(fields[1], 64)
+	} else {
+		q, err1 := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+		p, err2 := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+		if err1 != nil || err2 != nil {
+			return 0
+		}
+		quota, _ = strconv.ParseFloat(strings.TrimSpace(string(q)), 64)
+		period, _ = strconv.ParseFloat(strings.TrimSpace(string(p)), 64)
+	}
+	if quota <= 0 || period <= 0 {
+		return 0
+	}
+	return int(math.Ceil(quota / period))
+}
@@ -0,0 +1,59 @@
+package distro
+
+import (
+	"os/exec"
+	"strings"
+)
+
+var nativeOnlyDeps = map[string]bool{
+	"libclang-dev":           true,
+	"libglib2.0-dev-bin":     true,
+	"libglib2.0-bin":         true,
+	"libwayland-bin":         true,
+	"libxml2-utils":          true,
+	"libfile-fcntllock-perl": true,
+}
+
+func IsMultiArchSame(pkg string) bool {
+	if nativeOnlyDeps[pkg] {
+		return false
+	}
+	out, err := exec.Command("apt-cache", "show", "--no-all-versions", pkg).Output()
+	if err == nil {
+		for _, line := range strings.Split(string(out), "\n") {
+			if strings.HasPrefix(line, "Multi-Arch:") {
+				return strings.TrimSpace(strings.TrimPrefix(line, "Multi-Arch:")) == "same"
+			}
+		}
+		if len(out) > 0 {
+			return false
+		}
+	}
+	return strings.HasPrefix(pkg, "lib") && strings.HasSuffix(pkg, "-dev")
+}
+
+func CrossToolchainDeps(targetArch string) []string {
+	return []string{"crossbuild-essential-" + targetArch, "dpkg-cross"}
+}
+
+func CrossBuildDeps(deps []string, targetArch string) []string {
+	result := make([]string, 0, len(deps)+2)
+	seen := make(map[string]bool)
+	add := func(pkg string) {
+		if !seen[pkg] {
+			seen[pkg] = true
+			result = append(result, pkg)
+		}
+	}
+	for _, dep := range deps {
+		if IsMultiArchSame(dep) {
+			add(dep + ":" + targetArch)
+		} else {
+			add(dep)
+		}
+	}
+	for _, dep := range CrossToolchainDeps(targetArch) {
+		add(dep)
+	}
+	return result
+}
@@ -0,0 +1,272 @@
+package distro
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jimed-rand/cosmic-deb/pkg/cargo"
+)
+
+//go:embed preflight.json
+var builtinPreflight []byte
+
+type CrateRequirements struct {
+	PkgConfig []string `json:"pkg_config,omitempty"`
+	Headers   []string `json:"headers,omitempty"`
+	Tools     []string `json:"tools,omitempty"`
+	Libraries []string `json:"libraries,omitempty"`
+}
+
+type LibraryProbe struct {
+	Package string   `json:"package"`
+	Globs   []string `json:"globs"`
+}
+
+type PreflightTable struct {
+	Crates    map[string]CrateRequirements `json:"crates"`
+	PkgConfig map[string]string            `json:"pkg_config"`
+	Headers   map[string]string            `json:"headers"`
+	Tools     map[string]string            `json:"tools"`
+	Libraries map[string]LibraryProbe      `json:"libraries"`
+}
+
+var preflight = mustParsePreflight(builtinPreflight)
+
+func mustParsePreflight(data []byte) PreflightTable {
+	var t PreflightTable
+	if err := json.Unmarshal(data, &t); err != nil {
+		panic(err)
+	}
+	return t
+}
+
+type Requirement struct {
+	Kind         string
+	Name         string
+	Package      string
+	Crates       []string
+	LockfileOnly bool
+}
+
+func (r Requirement) String() string {
+	s := fmt.Sprintf("%s '%s'", r.Kind, r.Name)
+	if len(r.Crates) > 0 {
+		s += " (needed by " + strings.Join(r.Crates, ", ") + ")"
+	}
+	if r.Package != "" {
+		s += " is provided by " + r.Package
+	}
+	if r.LockfileOnly {
+		s += " [listed in Cargo.lock; may not be built]"
+	}
+	return s
+}
+
+type cargoResolve struct {
+	Packages []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"packages"`
+	WorkspaceMembers []string `json:"workspace_members"`
+	Resolve          struct {
+		Nodes []struct {
+			ID   string `json:"id"`
+			Deps []struct {
+				Pkg      string `json:"pkg"`
+				DepKinds []struct {
+					Kind *string `json:"kind"`
+				} `json:"dep_kinds"`
+			} `json:"deps"`
+		} `json:"nodes"`
+	} `json:"resolve"`
+}
+
+func resolvedCrates(repoDir, triple string, cargoArgs []string) (map[string]bool, error) {
+	args := []string{"metadata", "--format-version", "1", "--locked"}
+	if triple != "" {
+		args = append(args, "--filter-platform", triple)
+	}
+	cmd := exec.Command("cargo", append(args, cargoArgs...)...)
+	cmd.Dir = repoDir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("cargo metadata: %v", err)
+	}
+	var meta cargoResolve
+	if err := json.Unmarshal(out, &meta); err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(meta.Packages))
+	for _, p := range meta.Packages {
+		names[p.ID] = p.Name
+	}
+	edges := make(map[string][]string, len(meta.Resolve.Nodes))
+	for _, n := range meta.Resolve.Nodes {
+		for _, d := range n.Deps {
+			for _, k := range d.DepKinds {
+				if k.Kind == nil || *k.Kind != "dev" {
+					edges[n.ID] = append(edges[n.ID], d.Pkg)
+					break
+				}
+			}
+		}
+	}
+	crates := make(map[string]bool)
+	seen := make(map[string]bool)
+	queue := append([]string(nil), meta.WorkspaceMembers...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		crates[names[id]] = true
+		queue = append(queue, edges[id]...)
+	}
+	return crates, nil
+}
+
+func SourceRequirements(repoDir, triple string, cargoArgs []string) ([]Requirement, error) {
+	lockPath := filepath.Join(repoDir, "Cargo.lock")
+	if _, err := os.Stat(lockPath); os.IsNotExist(err) {
+		return nil, nil
+	}
+	lock, err := cargo.ParseLock(lockPath)
+	if err != nil {
+		return nil, err
+	}
+	built, resolveErr := resolvedCrates(repoDir, triple, cargoArgs)
+	byKey := make(map[string]*Requirement)
+	var keys []string
+	add := func(kind, name, pkg, crate string) {
+		key := kind + ":" + name
+		r, ok := byKey[key]
+		if !ok {
+			r = &Requirement{Kind: kind, Name: name, Package: pkg, LockfileOnly: resolveErr != nil}
+			byKey[key] = r
+			keys = append(keys, key)
+		}
+		for _, c := range r.Crates {
+			if c == crate {
+				return
+			}
+		}
+		r.Crates = append(r.Crates, crate)
+	}
+	for _, p := range lock.Packages {
+		req, ok := preflight.Crates[p.Name]
+		if !ok || resolveErr == nil && !built[p.Name] {
+			continue
+		}
+		for _, m := range req.PkgConfig {
+			add("pkg-config", m, preflight.PkgConfig[m], p.Name)
+		}
+		for _, h := range req.Headers {
+			add("header", h, preflight.Headers[h], p.Name)
+		}
+		for _, t := range req.Tools {
+			add("tool", t, preflight.Tools[t], p.Name)
+		}
+		for _, l := range req.Libraries {
+			add("library", l, preflight.Libraries[l].Package, p.Name)
+		}
+	}
+	sort.Strings(keys)
+	var reqs []Requirement
+	for _, k := range keys {
+		reqs = append(reqs, *byKey[k])
+	}
+	return reqs, nil
+}
+
+func (r Requirement) Satisfied() bool {
+	switch r.Kind {
+	case "pkg-config":
+		return exec.Command("pkg-config", "--exists", r.Name).Run() == nil
+	case "header":
+		for _, pattern := range []string{"/usr/include/", "/usr/include/*/", "/usr/local/include/"} {
+			if matches, _ := filepath.Glob(pattern + r.Name); len(matches) > 0 {
+				return true
+			}
+		}
+		return false
+	case "tool":
+		_, err := exec.LookPath(r.Name)
+		return err == nil
+	case "library":
+		for _, g := range preflight.Libraries[r.Name].Globs {
+			if matches, _ := filepath.Glob(g); len(matches) > 0 {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func Preflight(repoDir, triple string, cargoArgs []string) ([]Requirement, error) {
+	reqs, err := SourceRequirements(repoDir, triple, cargoArgs)
+	if err != nil {
+		return nil, err
+	}
+	_, pkgConfigErr := exec.LookPath("pkg-config")
+	var missing []Requirement
+	pkgConfigReported := false
+	for _, r := range reqs {
+		if r.Kind == "pkg-config" && pkgConfigErr != nil {
+			if !pkgConfigReported {
+				missing = append(missing, Requirement{Kind: "tool", Name: "pkg-config", Package: preflight.Tools["pkg-config"]})
+				pkgConfigReported = true
+			}
+			missing = append(missing, r)
+			continue
+		}
+		if !r.Satisfied() {
+			missing = append(missing, r)
+		}
+	}
+	return missing, nil
+}
+
+func RequirementPackages(reqs []Requirement) (pkgs []string, unknown []Requirement) {
+	seen := make(map[string]bool)
+	for _, r := range reqs {
+		if r.Package == "" {
+			unknown = append(unknown, r)
+			continue
+		}
+		if !seen[r.Package] {
+			seen[r.Package] = true
+			pkgs = append(pkgs, r.Package)
+		}
+	}
+	return pkgs, unknown
+}
+
+func ProviderFor(kind, name string) string {
+	switch kind {
+	case "pkg-config":
+		return preflight.PkgConfig[name]
+	case "header":
+		return preflight.Headers[name]
+	case "tool":
+		return preflight.Tools[name]
+	case "library":
+		if p, ok := preflight.Libraries[name]; ok {
+			return p.Package
+		}
+		for module, pkg := range preflight.PkgConfig {
+			if module == name || module == "lib"+name || strings.TrimPrefix(module, "lib") == name {
+				return pkg
+			}
+		}
+	}
+	return ""
+}
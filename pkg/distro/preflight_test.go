@@ -0,0 +1,114 @@
+package distro
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRequirementString(t *testing.T) {
+	tests := []struct {
+		req  Requirement
+		want string
+	}{
+		{Requirement{Kind: "tool", Name: "cmake"}, "tool 'cmake'"},
+		{Requirement{Kind: "tool", Name: "cmake", Package: "cmake"}, "tool 'cmake' is provided by cmake"},
+		{
+			Requirement{Kind: "pkg-config", Name: "fontconfig", Package: "libfontconfig-dev", Crates: []string{"servo-fontconfig-sys", "yeslogic-fontconfig-sys"}},
+			"pkg-config 'fontconfig' (needed by servo-fontconfig-sys, yeslogic-fontconfig-sys) is provided by libfontconfig-dev",
+		},
+		{
+			Requirement{Kind: "library", Name: "libclang", Crates: []string{"clang-sys"}, LockfileOnly: true},
+			"library 'libclang' (needed by clang-sys) [listed in Cargo.lock; may not be built]",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.req.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestSourceRequirementsLockfileOnly(t *testing.T) {
+	dir := t.TempDir()
+	lock := `version = 3
+
+[[package]]
+name = "clang-sys"
+version = "1.8.1"
+
+[[package]]
+name = "cmake"
+version = "0.1.50"
+
+[[package]]
+name = "serde"
+version = "1.0.200"
+
+[[package]]
+name = "servo-fontconfig-sys"
+version = "5.1.0"
+
+[[package]]
+name = "yeslogic-fontconfig-sys"
+version = "6.0.0"
+`
+	if err := os.WriteFile(filepath.Join(dir, "Cargo.lock"), []byte(lock), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reqs, err := SourceRequirements(dir, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Requirement{
+		{Kind: "library", Name: "libclang", Package: "libclang-dev", Crates: []string{"clang-sys"}, LockfileOnly: true},
+		{Kind: "pkg-config", Name: "fontconfig", Package: "libfontconfig-dev", Crates: []string{"servo-fontconfig-sys", "yeslogic-fontconfig-sys"}, LockfileOnly: true},
+		{Kind: "tool", Name: "cmake", Package: "cmake", Crates: []string{"cmake"}, LockfileOnly: true},
+	}
+	if !reflect.DeepEqual(reqs, want) {
+		t.Errorf("SourceRequirements =\n%+v\nwant\n%+v", reqs, want)
+	}
+}
+
+func TestSourceRequirementsNoLock(t *testing.T) {
+	reqs, err := SourceRequirements(t.TempDir(), "", nil)
+	if err != nil || reqs != nil {
+		t.Errorf("SourceRequirements without Cargo.lock = %v, %v, want nil, nil", reqs, err)
+	}
+}
+
+func TestRequirementPackages(t *testing.T) {
+	reqs := []Requirement{
+		{Kind: "pkg-config", Name: "freetype2", Package: "libfreetype-dev"},
+		{Kind: "header", Name: "foo.h"},
+		{Kind: "pkg-config", Name: "fontconfig", Package: "libfontconfig-dev"},
+		{Kind: "pkg-config", Name: "freetype2", Package: "libfreetype-dev"},
+	}
+	pkgs, unknown := RequirementPackages(reqs)
+	if want := []string{"libfreetype-dev", "libfontconfig-dev"}; !reflect.DeepEqual(pkgs, want) {
+		t.Errorf("packages = %v, want %v", pkgs, want)
+	}
+	if len(unknown) != 1 || unknown[0].Name != "foo.h" {
+		t.Errorf("unknown = %v, want foo.h only", unknown)
+	}
+}
+
+func TestProviderFor(t *testing.T) {
+	tests := []struct {
+		kind, name, want string
+	}{
+		{"pkg-config", "freetype2", "libfreetype-dev"},
+		{"header", "security/pam_appl.h", "libpam0g-dev"},
+		{"tool", "nasm", "nasm"},
+		{"library", "libclang", "libclang-dev"},
+		{"library", "expat", "libexpat1-dev"},
+		{"library", "nosuchlib", ""},
+		{"crate", "serde", ""},
+	}
+	for _, tt := range tests {
+		if got := ProviderFor(tt.kind, tt.name); got != tt.want {
+			t.Errorf("ProviderFor(%s, %s) = %q, want %q", tt.kind, tt.name, got, tt.want)
+		}
+	}
+}
@@ -10,9 +10,9 @@ import (
 	"time"
 )
 
-func Load(path string) (*Config, string) {
+func Load(path string) (*Config, string, error) {
 	if path == "built-in" {
-		return BuiltIn(), "built-in"
+		return BuiltIn(), "built-in", nil
 	}
 	paths := []string{path}
 	if !filepath.IsAbs(path) {
@@ -32,16 +32,36 @@ func Load(path string) (*Config, string) {
 		}
 	}
 	if err != nil {
-		return nil, ""
+		return nil, "", err
 	}
 	var cfg Config
 	if err := json.Unmarshal(data, &cfg); err != nil {
-		return nil, foundPath
+		return nil, foundPath, fmt.Errorf("cannot parse %s: %v", foundPath, err)
 	}
 	if len(cfg.Repos) == 0 {
-		return nil, foundPath
+		return nil, foundPath, fmt.Errorf("%s lists no repositories", foundPath)
+	}
+	migrateDeprecated(&cfg)
+	if err := Validate(&cfg); err != nil {
+		return nil, foundPath, err
+	}
+	return &cfg, foundPath, nil
+}
+
+func migrateDeprecated(cfg *Config) {
+	for i := range cfg.Repos {
+		b := cfg.Repos[i].Build
+		if b == nil || !b.DisableLTORewrite {
+			continue
+		}
+		b.DisableLTORewrite = false
+		if b.Profile == nil {
+			b.Profile = &CargoProfile{}
+		}
+		if b.Profile.Preset == "" {
+			b.Profile.Preset = "upstream"
+		}
 	}
-	return &cfg, foundPath
 }
 
 func latestEpochTag(repoURL string) string {
@@ -79,11 +99,7 @@ func Update(path string, existing *Config, logFn func(string, ...any)) *Config {
 	}
 	latestEpoch := ""
 	for _, repo := range existing.Repos {
-		entry := Entry{
-			Name:   repo.Name,
-			URL:    repo.URL,
-			Branch: repo.Branch,
-		}
+		entry := repo
 		tag := latestEpochTag(repo.URL)
 		if tag != "" {
 			entry.Tag = tag
@@ -169,10 +185,17 @@ func GenerateFinderGo(cfg *Config) (string, error) {
 	sb.WriteString(fmt.Sprintf("\t\tEpochLatest: %q,\n", cfg.EpochLatest))
 	sb.WriteString("\t\tRepos: []Entry{\n")
 	for _, r := range cfg.Repos {
+		extra := ""
+		if len(r.Requires) > 0 {
+			extra = fmt.Sprintf(", Requires: %#v", r.Requires)
+		}
+		if r.Build != nil {
+			extra += ", Build: " + buildSpecLiteral(r.Build)
+		}
 		if r.Branch != "" {
-			sb.WriteString(fmt.Sprintf("\t\t\t{Name: %q, URL: %q, Branch: %q},\n", r.Name, r.URL, r.Branch))
+			sb.WriteString(fmt.Sprintf("\t\t\t{Name: %q, URL: %q, Branch: %q%s},\n", r.Name, r.URL, r.Branch, extra))
 		} else {
-			sb.WriteString(fmt.Sprintf("\t\t\t{Name: %q, URL: %q, Tag: %q},\n", r.Name, r.URL, r.Tag))
+			sb.WriteString(fmt.Sprintf("\t\t\t{Name: %q, URL: %q, Tag: %q%s},\n", r.Name, r.URL, r.Tag, extra))
 		}
 	}
 	sb.WriteString("\t\t},\n\t}\n}\n")
@@ -181,3 +204,73 @@ func GenerateFinderGo(cfg *Config) (string, error) {
 func MarshalConfig(cfg *Config) ([]byte, error) {
 	return json.MarshalIndent(cfg, "", "  ")
 }
+
+func buildSpecLiteral(b *BuildSpec) string {
+	var fields []string
+	add := func(name string, value any, zero bool) {
+		addTo(&fields, name, value, zero)
+	}
+	add("System", b.System, b.System == "")
+	add("Env", b.Env, len(b.Env) == 0)
+	add("Features", b.Features, len(b.Features) == 0)
+	add("NoDefaultFeatures", b.NoDefaultFeatures, !b.NoDefaultFeatures)
+	if r := b.Recipes; r != nil {
+		var rf []string
+		addTo(&rf, "Vendor", r.Vendor, r.Vendor == "")
+		addTo(&rf, "Build", r.Build, r.Build == "")
+		addTo(&rf, "Install", r.Install, r.Install == "")
+		fields = append(fields, "Recipes: &Recipes{"+strings.Join(rf, ", ")+"}")
+	}
+	add("InstallVars", b.InstallVars, len(b.InstallVars) == 0)
+	if p := b.Profile; p != nil {
+		var pf []string
+		addTo(&pf, "Preset", p.Preset, p.Preset == "")
+		addTo(&pf, "LTO", p.LTO, p.LTO == "")
+		addTo(&pf, "CodegenUnits", p.CodegenUnits, p.CodegenUnits == 0)
+		addTo(&pf, "OptLevel", p.OptLevel, p.OptLevel == "")
+		addTo(&pf, "Debug", p.Debug, p.Debug == "")
+		addTo(&pf, "Panic", p.Panic, p.Panic == "")
+		addTo(&pf, "TargetCPU", p.TargetCPU, p.TargetCPU == "")
+		fields = append(fields, "Profile: &CargoProfile{"+strings.Join(pf, ", ")+"}")
+	}
+	add("Toolchain", b.Toolchain, b.Toolchain == "")
+	add("ExtraDeps", b.ExtraDeps, len(b.ExtraDeps) == 0)
+	add("SkipTests", b.SkipTests, !b.SkipTests)
+	if p := b.Packaging; p != nil {
+		var pf []string
+		addTo(&pf, "Depends", p.Depends, len(p.Depends) == 0)
+		addTo(&pf, "Recommends", p.Recommends, len(p.Recommends) == 0)
+		addTo(&pf, "Conflicts", p.Conflicts, len(p.Conflicts) == 0)
+		addTo(&pf, "Section", p.Section, p.Section == "")
+		addTo(&pf, "Description", p.Description, p.Description == "")
+		if len(p.Split) > 0 {
+			var rules []string
+			for _, r := range p.Split {
+				rules = append(rules, splitRuleLiteral(r))
+			}
+			pf = append(pf, "Split: []SplitRule{"+strings.Join(rules, ", ")+"}")
+		}
+		fields = append(fields, "Packaging: &PackagingSpec{"+strings.Join(pf, ", ")+"}")
+	}
+	return "&BuildSpec{" + strings.Join(fields, ", ") + "}"
+}
+
+func splitRuleLiteral(r SplitRule) string {
+	var fields []string
+	addTo(&fields, "Package", r.Package, false)
+	addTo(&fields, "Patterns", r.Patterns, false)
+	addTo(&fields, "Depends", r.Depends, len(r.Depends) == 0)
+	addTo(&fields, "Recommends", r.Recommends, len(r.Recommends) == 0)
+	addTo(&fields, "Section", r.Section, r.Section == "")
+	addTo(&fields, "Description", r.Description, r.Description == "")
+	addTo(&fields, "ParentDepends", r.ParentDepends, !r.ParentDepends)
+	addTo(&fields, "ParentRecommends", r.ParentRecommends, !r.ParentRecommends)
+	addTo(&fields, "DependsOnParent", r.DependsOnParent, !r.DependsOnParent)
+	return "{" + strings.Join(fields, ", ") + "}"
+}
+
+func addTo(fields *[]string, name string, value any, zero bool) {
+	if !zero {
+		*fields = append(*fields, fmt.Sprintf("%s: %#v", name, value))
+	}
+}
@@ -0,0 +1,79 @@
+package repos
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var CargoProfilePresets = []string{"auto", "balanced", "lowmem", "release", "upstream"}
+
+var (
+	ltoModes     = []string{"fat", "thin", "off", "true", "false"}
+	optLevels    = []string{"0", "1", "2", "3", "s", "z"}
+	debugLevels  = []string{"0", "1", "2", "none", "line-directives-only", "line-tables-only", "limited", "full", "true", "false"}
+	panicModes   = []string{"unwind", "abort"}
+	reTargetCPU  = regexp.MustCompile(`^[A-Za-z0-9_.+-]+$`)
+	profileUsage = "preset (auto|balanced|lowmem|release|upstream) and/or lto=, codegen-units=, opt-level=, debuginfo=, panic=, target-cpu="
+)
+
+func ParseCargoProfile(s string) (CargoProfile, error) {
+	var p CargoProfile
+	for _, tok := range strings.Split(s, ",") {
+		tok = strings.TrimSpace(tok)
+		if tok == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(tok, "=")
+		if !ok {
+			p.Preset = tok
+			continue
+		}
+		switch key {
+		case "lto":
+			p.LTO = value
+		case "codegen-units":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return p, fmt.Errorf("invalid codegen-units '%s'", value)
+			}
+			p.CodegenUnits = n
+		case "opt-level":
+			p.OptLevel = value
+		case "debuginfo":
+			p.Debug = value
+		case "panic":
+			p.Panic = value
+		case "target-cpu":
+			p.TargetCPU = value
+		default:
+			return p, fmt.Errorf("unknown cargo profile setting '%s' (expected %s)", key, profileUsage)
+		}
+	}
+	if problems := validateCargoProfile(&p); len(problems) > 0 {
+		return p, fmt.Errorf("invalid cargo profile: %s", strings.Join(problems, "; "))
+	}
+	return p, nil
+}
+
+func validateCargoProfile(p *CargoProfile) []string {
+	var problems []string
+	check := func(field, value string, allowed []string) {
+		if value != "" && !contains(allowed, value) {
+			problems = append(problems, fmt.Sprintf("%s: invalid value '%s' (allowed: %s)", field, value, strings.Join(allowed, ", ")))
+		}
+	}
+	check("preset", p.Preset, CargoProfilePresets)
+	check("lto", p.LTO, ltoModes)
+	check("opt_level", p.OptLevel, optLevels)
+	check("debuginfo", p.Debug, debugLevels)
+	check("panic", p.Panic, panicModes)
+	if p.CodegenUnits < 0 {
+		problems = append(problems, fmt.Sprintf("codegen_units: must be positive, got %d", p.CodegenUnits))
+	}
+	if p.TargetCPU != "" && !reTargetCPU.MatchString(p.TargetCPU) {
+		problems = append(problems, fmt.Sprintf("target_cpu: invalid value '%s'", p.TargetCPU))
+	}
+	return problems
+}
@@ -0,0 +1,109 @@
+package repos
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+func ResolveTargets(cfg *Config, names []string) ([]Entry, error) {
+	byName := make(map[string]Entry, len(cfg.Repos))
+	for _, r := range cfg.Repos {
+		byName[r.Name] = r
+	}
+	if len(names) == 0 {
+		return OrderTargets(cfg.Repos)
+	}
+	selected := make(map[string]bool)
+	var visit func(name, via string) error
+	visit = func(name, via string) error {
+		if selected[name] {
+			return nil
+		}
+		entry, ok := byName[name]
+		if !ok {
+			if via != "" {
+				return fmt.Errorf("component '%s' (required by '%s') not found in repos config", name, via)
+			}
+			return fmt.Errorf("component '%s' not found in repos config", name)
+		}
+		selected[name] = true
+		for _, req := range entry.Requires {
+			if err := visit(req, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, n := range names {
+		if err := visit(n, ""); err != nil {
+			return nil, err
+		}
+	}
+	var entries []Entry
+	for _, r := range cfg.Repos {
+		if selected[r.Name] {
+			entries = append(entries, r)
+		}
+	}
+	return OrderTargets(entries)
+}
+
+func OrderTargets(entries []Entry) ([]Entry, error) {
+	byName := make(map[string]Entry, len(entries))
+	for _, e := range entries {
+		byName[e.Name] = e
+	}
+	pending := make(map[string]int)
+	dependents := make(map[string][]string)
+	for _, e := range entries {
+		pending[e.Name] += 0
+		for _, req := range e.Requires {
+			if _, ok := byName[req]; !ok {
+				continue
+			}
+			pending[e.Name]++
+			dependents[req] = append(dependents[req], e.Name)
+		}
+	}
+	var ready []string
+	for name, n := range pending {
+		if n == 0 {
+			ready = append(ready, name)
+		}
+	}
+	var ordered []Entry
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		name := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, byName[name])
+		for _, dep := range dependents[name] {
+			pending[dep]--
+			if pending[dep] == 0 {
+				ready = append(ready, dep)
+			}
+		}
+	}
+	if len(ordered) != len(entries) {
+		var cyclic []string
+		for name, n := range pending {
+			if n > 0 {
+				cyclic = append(cyclic, name)
+			}
+		}
+		sort.Strings(cyclic)
+		return nil, fmt.Errorf("dependency cycle between components: %s", strings.Join(cyclic, ", "))
+	}
+	return ordered, nil
+}
+
+func ParseComponentList(s string) []string {
+	var names []string
+	for _, n := range strings.Split(s, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			names = append(names, n)
+		}
+	}
+	return names
+}
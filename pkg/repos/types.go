@@ -1,10 +1,64 @@
 package repos
 
 type Entry struct {
-	Name   string `json:"name"`
-	URL    string `json:"url"`
-	Tag    string `json:"tag"`
-	Branch string `json:"branch,omitempty"`
+	Name     string     `json:"name"`
+	URL      string     `json:"url"`
+	Tag      string     `json:"tag"`
+	Branch   string     `json:"branch,omitempty"`
+	Requires []string   `json:"requires,omitempty"`
+	Build    *BuildSpec `json:"build,omitempty"`
+}
+
+type BuildSpec struct {
+	System            string            `json:"system,omitempty"`
+	Env               map[string]string `json:"env,omitempty"`
+	Features          []string          `json:"features,omitempty"`
+	NoDefaultFeatures bool              `json:"no_default_features,omitempty"`
+	Recipes           *Recipes          `json:"recipes,omitempty"`
+	InstallVars       map[string]string `json:"install_vars,omitempty"`
+	DisableLTORewrite bool              `json:"disable_lto_rewrite,omitempty"`
+	Profile           *CargoProfile     `json:"profile,omitempty"`
+	Toolchain         string            `json:"toolchain,omitempty"`
+	ExtraDeps         []string          `json:"extra_deps,omitempty"`
+	SkipTests         bool              `json:"skip_tests,omitempty"`
+	Packaging         *PackagingSpec    `json:"packaging,omitempty"`
+}
+
+type Recipes struct {
+	Vendor  string `json:"vendor,omitempty"`
+	Build   string `json:"build,omitempty"`
+	Install string `json:"install,omitempty"`
+}
+
+type CargoProfile struct {
+	Preset       string `json:"preset,omitempty"`
+	LTO          string `json:"lto,omitempty"`
+	CodegenUnits int    `json:"codegen_units,omitempty"`
+	OptLevel     string `json:"opt_level,omitempty"`
+	Debug        string `json:"debuginfo,omitempty"`
+	Panic        string `json:"panic,omitempty"`
+	TargetCPU    string `json:"target_cpu,omitempty"`
+}
+
+type PackagingSpec struct {
+	Depends     []string    `json:"depends,omitempty"`
+	Recommends  []string    `json:"recommends,omitempty"`
+	Conflicts   []string    `json:"conflicts,omitempty"`
+	Section     string      `json:"section,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Split       []SplitRule `json:"split,omitempty"`
+}
+
+type SplitRule struct {
+	Package          string   `json:"package"`
+	Patterns         []string `json:"patterns"`
+	Depends          []string `json:"depends,omitempty"`
+	Recommends       []string `json:"recommends,omitempty"`
+	Section          string   `json:"section,omitempty"`
+	Description      string   `json:"description,omitempty"`
+	ParentDepends    bool     `json:"parent_depends,omitempty"`
+	ParentRecommends bool     `json:"parent_recommends,omitempty"`
+	DependsOnParent  bool     `json:"depends_on_parent,omitempty"`
 }
 
 type Config struct {
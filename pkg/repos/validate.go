@@ -0,0 +1,144 @@
+package repos
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var BuildSystems = []string{"auto", "cargo", "debian", "just", "make", "meson"}
+
+var (
+	reEnvName     = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	reFeature     = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_+./-]*$`)
+	reRecipe      = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_-]*$`)
+	rePackageName = regexp.MustCompile(`^[a-z0-9][a-z0-9+.-]+$`)
+	reSection     = regexp.MustCompile(`^[a-z0-9][a-z0-9/-]*$`)
+	reToolchain   = regexp.MustCompile(`^(stable|beta|nightly|[0-9]+\.[0-9]+(\.[0-9]+)?)(-[0-9]{4}-[0-9]{2}-[0-9]{2})?(-[a-z0-9_]+)*$`)
+)
+
+func Validate(cfg *Config) error {
+	var problems []string
+	seen := make(map[string]bool)
+	for i, r := range cfg.Repos {
+		where := r.Name
+		if where == "" {
+			where = fmt.Sprintf("repos[%d]", i)
+			problems = append(problems, where+": missing name")
+		} else if seen[r.Name] {
+			problems = append(problems, where+": duplicate entry")
+		}
+		seen[r.Name] = true
+		if r.URL == "" {
+			problems = append(problems, where+": missing url")
+		}
+		if r.Build != nil {
+			for _, p := range validateBuildSpec(r.Build) {
+				problems = append(problems, where+": build."+p)
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid repos config:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func CheckToolchain(name string) error {
+	if !reToolchain.MatchString(name) {
+		return fmt.Errorf("invalid Rust toolchain '%s' (expected stable, beta, nightly or a version such as 1.85.0, optionally dated)", name)
+	}
+	return nil
+}
+
+func validateBuildSpec(b *BuildSpec) []string {
+	var problems []string
+	if b.System != "" && !contains(BuildSystems, b.System) {
+		problems = append(problems, fmt.Sprintf("system: unknown build system '%s' (known: %s)", b.System, strings.Join(BuildSystems, ", ")))
+	}
+	for k := range b.Env {
+		if !reEnvName.MatchString(k) {
+			problems = append(problems, fmt.Sprintf("env: invalid variable name '%s'", k))
+		}
+	}
+	for _, f := range b.Features {
+		if !reFeature.MatchString(f) {
+			problems = append(problems, fmt.Sprintf("features: invalid feature name '%s'", f))
+		}
+	}
+	if len(b.Features) > 0 || b.NoDefaultFeatures {
+		switch b.System {
+		case "debian", "meson":
+			problems = append(problems, fmt.Sprintf("features: not supported by the %s build system", b.System))
+		}
+	}
+	if b.Recipes != nil {
+		for field, name := range map[string]string{"vendor": b.Recipes.Vendor, "build": b.Recipes.Build, "install": b.Recipes.Install} {
+			if name != "" && !reRecipe.MatchString(name) {
+				problems = append(problems, fmt.Sprintf("recipes.%s: invalid recipe name '%s'", field, name))
+			}
+		}
+	}
+	for k := range b.InstallVars {
+		if !reEnvName.MatchString(k) {
+			problems = append(problems, fmt.Sprintf("install_vars: invalid variable name '%s'", k))
+		}
+	}
+	if b.Profile != nil {
+		for _, p := range validateCargoProfile(b.Profile) {
+			problems = append(problems, "profile."+p)
+		}
+	}
+	if b.Toolchain != "" {
+		if err := CheckToolchain(b.Toolchain); err != nil {
+			problems = append(problems, "toolchain: "+err.Error())
+		}
+	}
+	if b.SkipTests && b.System != "" && b.System != "auto" && b.System != "debian" {
+		problems = append(problems, fmt.Sprintf("skip_tests: not supported by the %s build system, which runs no tests", b.System))
+	}
+	for _, d := range b.ExtraDeps {
+		if !rePackageName.MatchString(d) {
+			problems = append(problems, fmt.Sprintf("extra_deps: invalid package name '%s'", d))
+		}
+	}
+	if p := b.Packaging; p != nil {
+		for field, list := range map[string][]string{"depends": p.Depends, "recommends": p.Recommends, "conflicts": p.Conflicts} {
+			for _, rel := range list {
+				if strings.TrimSpace(rel) == "" || strings.ContainsAny(rel, ",\n") {
+					problems = append(problems, fmt.Sprintf("packaging.%s: invalid relation '%s'", field, rel))
+				}
+			}
+		}
+		if p.Section != "" && !reSection.MatchString(p.Section) {
+			problems = append(problems, fmt.Sprintf("packaging.section: invalid section '%s'", p.Section))
+		}
+		if strings.Contains(p.Description, "\n") {
+			problems = append(problems, "packaging.description: must be a single line")
+		}
+		for i, r := range p.Split {
+			where := fmt.Sprintf("packaging.split[%d]", i)
+			if !rePackageName.MatchString(r.Package) {
+				problems = append(problems, fmt.Sprintf("%s.package: invalid package name '%s'", where, r.Package))
+			}
+			if len(r.Patterns) == 0 {
+				problems = append(problems, where+".patterns: at least one pattern is required")
+			}
+			if r.Section != "" && !reSection.MatchString(r.Section) {
+				problems = append(problems, fmt.Sprintf("%s.section: invalid section '%s'", where, r.Section))
+			}
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
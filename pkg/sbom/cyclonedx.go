@@ -0,0 +1,165 @@
+package sbom
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/jimed-rand/cosmic-deb/pkg/cargo"
+)
+
+const (
+	specVersion = "1.5"
+	FileSuffix  = ".cdx.json"
+)
+
+type Document struct {
+	BOMFormat    string       `json:"bomFormat"`
+	SpecVersion  string       `json:"specVersion"`
+	SerialNumber string       `json:"serialNumber"`
+	Version      int          `json:"version"`
+	Metadata     Metadata     `json:"metadata"`
+	Components   []Component  `json:"components"`
+	Dependencies []Dependency `json:"dependencies,omitempty"`
+}
+
+type Metadata struct {
+	Timestamp string     `json:"timestamp"`
+	Tools     []Tool     `json:"tools"`
+	Component Component  `json:"component"`
+	Props     []Property `json:"properties,omitempty"`
+}
+
+type Tool struct {
+	Name string `json:"name"`
+}
+
+type Component struct {
+	BOMRef     string     `json:"bom-ref"`
+	Type       string     `json:"type"`
+	Name       string     `json:"name"`
+	Version    string     `json:"version"`
+	PURL       string     `json:"purl,omitempty"`
+	Hashes     []Hash     `json:"hashes,omitempty"`
+	Properties []Property `json:"properties,omitempty"`
+}
+
+type Hash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type Property struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type Dependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+func FromSource(repoDir, pkgName, version string) (*Document, error) {
+	lockPath := filepath.Join(repoDir, "Cargo.lock")
+	lock, err := cargo.ParseLock(lockPath)
+	if err != nil {
+		return nil, err
+	}
+	vendored, err := cargo.VendorInventory(repoDir)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  specVersion,
+		SerialNumber: serialNumber(pkgName, version, lockPath),
+		Version:      1,
+		Metadata: Metadata{
+			Timestamp: timestamp(),
+			Tools:     []Tool{{Name: "cosmic-deb"}},
+			Component: Component{
+				BOMRef:  "pkg:deb/" + pkgName + "@" + version,
+				Type:    "application",
+				Name:    pkgName,
+				Version: version,
+				PURL:    "pkg:deb/" + pkgName + "@" + version,
+			},
+			Props: []Property{
+				{Name: "cosmic-deb:cargo-lock-version", Value: strconv.Itoa(lock.Version)},
+				{Name: "cosmic-deb:vendored-crates", Value: strconv.Itoa(len(vendored))},
+			},
+		},
+	}
+
+	rootDeps := []string{}
+	for _, p := range cargo.SortedPackages(lock) {
+		c := Component{
+			BOMRef:  p.PURL(),
+			Type:    "library",
+			Name:    p.Name,
+			Version: p.Version,
+			PURL:    p.PURL(),
+		}
+		if p.Checksum != "" {
+			c.Hashes = []Hash{{Alg: "SHA-256", Content: p.Checksum}}
+		}
+		if p.Source != "" {
+			c.Properties = append(c.Properties, Property{Name: "cosmic-deb:source", Value: p.Source})
+		}
+		if _, ok := vendored[p.Name+"@"+p.Version]; ok {
+			c.Properties = append(c.Properties, Property{Name: "cosmic-deb:vendored", Value: "true"})
+		}
+		if p.IsLocal() {
+			c.Properties = append(c.Properties, Property{Name: "cosmic-deb:workspace-member", Value: "true"})
+			rootDeps = append(rootDeps, c.BOMRef)
+		}
+		doc.Components = append(doc.Components, c)
+
+		dep := Dependency{Ref: c.BOMRef}
+		for _, d := range p.Dependencies {
+			if target, ok := lock.Resolve(d); ok {
+				dep.DependsOn = append(dep.DependsOn, target.PURL())
+			}
+		}
+		doc.Dependencies = append(doc.Dependencies, dep)
+	}
+	doc.Dependencies = append([]Dependency{{Ref: doc.Metadata.Component.BOMRef, DependsOn: rootDeps}}, doc.Dependencies...)
+	return doc, nil
+}
+
+func (d *Document) Write(path string) error {
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func serialNumber(pkgName, version, lockPath string) string {
+	h := sha1.New()
+	h.Write([]byte(pkgName + "\x00" + version + "\x00"))
+	if data, err := os.ReadFile(lockPath); err == nil {
+		h.Write(data)
+	}
+	sum := h.Sum(nil)
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+func timestamp() string {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		if n, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			return time.Unix(n, 0).UTC().Format(time.RFC3339)
+		}
+	}
+	return time.Now().UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,107 @@
+package sbom
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+const testLock = `version = 3
+
+[[package]]
+name = "app"
+version = "0.1.0"
+dependencies = [
+ "serde",
+]
+
+[[package]]
+name = "serde"
+version = "1.0.200"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+checksum = "deadbeef"
+`
+
+func TestFromSource(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Cargo.lock"), []byte(testLock), 0644); err != nil {
+		t.Fatal(err)
+	}
+	vendored := filepath.Join(dir, "vendor", "serde")
+	if err := os.MkdirAll(vendored, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(vendored, "Cargo.toml"), []byte("[package]\nname = \"serde\"\nversion = \"1.0.200\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+
+	doc, err := FromSource(dir, "cosmic-app", "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Metadata.Timestamp != "2023-11-14T22:13:20Z" {
+		t.Errorf("Timestamp = %q, want SOURCE_DATE_EPOCH", doc.Metadata.Timestamp)
+	}
+	if doc.Metadata.Component.PURL != "pkg:deb/cosmic-app@1.0.0" {
+		t.Errorf("Component.PURL = %q", doc.Metadata.Component.PURL)
+	}
+
+	wantComponents := []Component{
+		{
+			BOMRef: "pkg:cargo/app@0.1.0", Type: "library", Name: "app", Version: "0.1.0", PURL: "pkg:cargo/app@0.1.0",
+			Properties: []Property{{Name: "cosmic-deb:workspace-member", Value: "true"}},
+		},
+		{
+			BOMRef: "pkg:cargo/serde@1.0.200", Type: "library", Name: "serde", Version: "1.0.200", PURL: "pkg:cargo/serde@1.0.200",
+			Hashes: []Hash{{Alg: "SHA-256", Content: "deadbeef"}},
+			Properties: []Property{
+				{Name: "cosmic-deb:source", Value: "registry+https://github.com/rust-lang/crates.io-index"},
+				{Name: "cosmic-deb:vendored", Value: "true"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(doc.Components, wantComponents) {
+		t.Errorf("Components = %+v, want %+v", doc.Components, wantComponents)
+	}
+
+	wantDeps := []Dependency{
+		{Ref: "pkg:deb/cosmic-app@1.0.0", DependsOn: []string{"pkg:cargo/app@0.1.0"}},
+		{Ref: "pkg:cargo/app@0.1.0", DependsOn: []string{"pkg:cargo/serde@1.0.200"}},
+		{Ref: "pkg:cargo/serde@1.0.200"},
+	}
+	if !reflect.DeepEqual(doc.Dependencies, wantDeps) {
+		t.Errorf("Dependencies = %+v, want %+v", doc.Dependencies, wantDeps)
+	}
+}
+
+func TestSerialNumber(t *testing.T) {
+	lock := filepath.Join(t.TempDir(), "Cargo.lock")
+	if err := os.WriteFile(lock, []byte(testLock), 0644); err != nil {
+		t.Fatal(err)
+	}
+	uuid := regexp.MustCompile(`^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	tests := []struct {
+		pkg, version string
+	}{
+		{"cosmic-app", "1.0.0"},
+		{"cosmic-app", "1.0.1"},
+		{"cosmic-term", "1.0.0"},
+	}
+	seen := make(map[string]bool)
+	for _, tt := range tests {
+		got := serialNumber(tt.pkg, tt.version, lock)
+		if !uuid.MatchString(got) {
+			t.Errorf("serialNumber(%s, %s) = %q, not a version 5 UUID URN", tt.pkg, tt.version, got)
+		}
+		if again := serialNumber(tt.pkg, tt.version, lock); again != got {
+			t.Errorf("serialNumber(%s, %s) is not stable: %q then %q", tt.pkg, tt.version, got, again)
+		}
+		if seen[got] {
+			t.Errorf("serialNumber(%s, %s) = %q collides with an earlier input", tt.pkg, tt.version, got)
+		}
+		seen[got] = true
+	}
+}
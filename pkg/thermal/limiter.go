@@ -8,6 +8,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/jimed-rand/cosmic-deb/pkg/distro"
 )
 
 const (
@@ -18,10 +20,14 @@ const (
 	CooldownMax           = 45 * time.Minute
 	LowEndCoreThreshold   = 2
 	LowEndThreadThreshold = 2
+	LowMemoryThresholdMiB = 8192
 )
 
 type Profile struct {
+	Environment       distro.Environment
 	IsLowEnd          bool
+	LowMemory         bool
+	MemTotalMiB       int
 	PhysicalCores     int
 	LogicalThreads    int
 	MaxConcurrentJobs int
@@ -104,11 +110,18 @@ func ReadCPUTempFromHwmon() ThermalState {
 }
 
 func DetectProfile() Profile {
+	env := distro.DetectEnvironment()
 	logical := runtime.NumCPU()
 	physical := detectPhysicalCores()
 	if physical <= 0 {
 		physical = logical
 	}
+	if env.CPULimit > 0 && env.CPULimit < logical {
+		logical = env.CPULimit
+		if physical > logical {
+			physical = logical
+		}
+	}
 
 	isLowEnd := physical <= LowEndCoreThreshold && logical <= LowEndThreadThreshold
 
@@ -118,10 +131,18 @@ func DetectProfile() Profile {
 	if isLowEnd {
 		maxJobs = 1
 		cooldown = CooldownMin
+		if env.Virtualized() {
+			cooldown = 0
+		}
 	}
 
+	memMiB := detectMemTotalMiB()
+
 	return Profile{
+		Environment:       env,
 		IsLowEnd:          isLowEnd,
+		LowMemory:         memMiB > 0 && memMiB < LowMemoryThresholdMiB,
+		MemTotalMiB:       memMiB,
 		PhysicalCores:     physical,
 		LogicalThreads:    logical,
 		MaxConcurrentJobs: maxJobs,
@@ -129,6 +150,24 @@ func DetectProfile() Profile {
 	}
 }
 
+func detectMemTotalMiB() int {
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "MemTotal:" {
+			kb, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return 0
+			}
+			return kb / 1024
+		}
+	}
+	return 0
+}
+
 func detectPhysicalCores() int {
 	data, err := os.ReadFile("/proc/cpuinfo")
 	if err != nil {
@@ -184,6 +223,11 @@ func WaitForCooldown(profile Profile, builtCount int, logFn func(string, ...any)
 		return
 	}
 
+	if profile.Environment.Virtualized() {
+		logFn("[Thermal] Running under %s; host cooling is not observable, skipping cooldown", profile.Environment)
+		return
+	}
+
 	state := ReadCPUTempFromHwmon()
 
 	var cooldown time.Duration
@@ -255,7 +299,18 @@ func SummarizeThermalProfile(profile Profile, logFn func(string, ...any)) {
 	if profile.IsLowEnd {
 		logFn("[Thermal] Low-end CPU profile active: %d physical core(s), %d thread(s)",
 			profile.PhysicalCores, profile.LogicalThreads)
-		logFn("[Thermal] Build limiter enabled: max %d parallel job(s), cooldown after every 2 components",
-			profile.MaxConcurrentJobs)
+		if profile.Environment.Virtualized() {
+			logFn("[Thermal] Build limiter enabled: max %d parallel job(s); cooldowns disabled under %s",
+				profile.MaxConcurrentJobs, profile.Environment)
+		} else {
+			logFn("[Thermal] Build limiter enabled: max %d parallel job(s), cooldown after every 2 components",
+				profile.MaxConcurrentJobs)
+		}
+	}
+	if profile.LowMemory {
+		logFn("[Thermal] Low-memory host: %d MiB of RAM", profile.MemTotalMiB)
+	}
+	if profile.Environment.CPULimit > 0 {
+		logFn("[Thermal] cgroup CPU quota limits the build to %d CPU(s)", profile.Environment.CPULimit)
 	}
 }
@@ -2,8 +2,14 @@
 set -e
 
 COSMIC_PACKAGES=(
-    cosmic-app-library cosmic-applets cosmic-bg cosmic-comp cosmic-desktop
-    cosmic-edit cosmic-files cosmic-greeter cosmic-icons cosmic-idle
+    cosmic-app-library cosmic-applets cosmic-applets-data cosmic-bg cosmic-comp
+    cosmic-applet-a11y cosmic-applet-audio cosmic-applet-battery
+    cosmic-applet-bluetooth cosmic-applet-input-sources cosmic-applet-minimize
+    cosmic-applet-network cosmic-applet-notifications cosmic-applet-power
+    cosmic-applet-status-area cosmic-applet-tiling cosmic-applet-time
+    cosmic-applet-workspaces
+    cosmic-desktop cosmic-edit cosmic-files cosmic-greeter cosmic-greeter-daemon
+    cosmic-icons cosmic-idle
     cosmic-initial-setup cosmic-launcher cosmic-notifications cosmic-osd
     cosmic-panel cosmic-player cosmic-randr cosmic-screenshot cosmic-session
     cosmic-settings cosmic-settings-daemon cosmic-store cosmic-term